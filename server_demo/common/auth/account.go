@@ -0,0 +1,70 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:51
+@Description: configured accounts with password hashes and roles
+*/
+
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+const (
+	RoleOperator = "operator" // admin routes and everything a shop manager can do
+	RoleShop     = "shop"     // shop inventory editing
+)
+
+// Account is a staff login from the config, players are guests and have no account.
+type Account struct {
+	Name  string
+	Uid   uint64
+	Roles []string
+	hash  []byte // sha256 of the password
+}
+
+// Accounts maps name to account.
+type Accounts map[string]*Account
+
+// AccountUid derives a stable uid from the name, far from the snowflake range of guests.
+func AccountUid(name string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte("account:" + name))
+	return h.Sum64() | 1<<63
+}
+
+// Add parses entries of "name:sha256hex" and grants role to each, a name seen again gets one more role.
+func (a Accounts) Add(role string, entries ...string) error {
+	for _, entry := range entries {
+		name, hexHash, ok := strings.Cut(entry, ":")
+		hash, err := hex.DecodeString(hexHash)
+		if !ok || name == "" || err != nil || len(hash) != sha256.Size {
+			return fmt.Errorf("auth: account %q must be name:sha256hex", name)
+		}
+
+		acc, ok := a[name]
+		if !ok {
+			acc = &Account{Name: name, Uid: AccountUid(name), hash: hash}
+			a[name] = acc
+		} else if subtle.ConstantTimeCompare(acc.hash, hash) != 1 {
+			return fmt.Errorf("auth: account %q has different passwords", name)
+		}
+		acc.Roles = append(acc.Roles, role)
+	}
+	return nil
+}
+
+// Check returns the account when name and password match.
+func (a Accounts) Check(name, password string) (*Account, bool) {
+	sum := sha256.Sum256([]byte(password))
+	acc, ok := a[name]
+	if !ok || subtle.ConstantTimeCompare(acc.hash, sum[:]) != 1 {
+		return nil, false
+	}
+	return acc, true
+}
@@ -0,0 +1,101 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:49
+@Description: revoked token ids kept until they expire
+*/
+
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/idgen"
+)
+
+const revokeSweepInterval = time.Minute
+
+// Revocation remembers revoked tokens until they expire anyway, and uids whose
+// tokens issued before a time are all revoked (logout from every device).
+type Revocation struct {
+	mu        sync.Mutex
+	tokens    map[int64]int64  // token id -> expire unix
+	uids      map[uint64]int64 // uid -> tokens with an id up to this one are revoked
+	maxTTL    time.Duration
+	lastSweep time.Time
+}
+
+// NewRevocation keeps uid revocations for maxTTL, the longest a token can live.
+func NewRevocation(maxTTL time.Duration) *Revocation {
+	return &Revocation{
+		tokens:    make(map[int64]int64),
+		uids:      make(map[uint64]int64),
+		maxTTL:    maxTTL,
+		lastSweep: time.Now(),
+	}
+}
+
+// Revoke invalidates one token.
+func (r *Revocation) Revoke(c *Claims) {
+	r.mu.Lock()
+	r.tokens[c.Id] = c.Expire
+	r.mu.Unlock()
+}
+
+// RevokeUid invalidates every token of uid issued up to now, token ids grow with time
+// so a login right after still works.
+func (r *Revocation) RevokeUid(uid uint64) {
+	r.mu.Lock()
+	r.uids[uid] = idgen.Next()
+	r.mu.Unlock()
+}
+
+// RevokeIfNot revokes c and reports whether it was still valid, checking and revoking under one
+// lock so of two callers with the same token only one gets true.
+func (r *Revocation) RevokeIfNot(c *Claims) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.revoked(c) {
+		return false
+	}
+	r.tokens[c.Id] = c.Expire
+	return true
+}
+
+func (r *Revocation) Revoked(c *Claims) bool {
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now.Sub(r.lastSweep) >= revokeSweepInterval {
+		r.sweep(now)
+	}
+	return r.revoked(c)
+}
+
+// revoked reports whether c is revoked, r.mu must be held.
+func (r *Revocation) revoked(c *Claims) bool {
+	if _, ok := r.tokens[c.Id]; ok {
+		return true
+	}
+	before, ok := r.uids[c.Uid]
+	return ok && c.Id <= before
+}
+
+// sweep forgets entries of tokens that are expired by now, r.mu must be held.
+func (r *Revocation) sweep(now time.Time) {
+	unix := now.Unix()
+	for id, expire := range r.tokens {
+		if expire <= unix {
+			delete(r.tokens, id)
+		}
+	}
+	for uid, before := range r.uids {
+		if now.Sub(idgen.Time(before)) >= r.maxTTL {
+			delete(r.uids, uid)
+		}
+	}
+	r.lastSweep = now
+}
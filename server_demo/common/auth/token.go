@@ -0,0 +1,136 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:49
+@Description: hmac signed session tokens
+*/
+
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/idgen"
+)
+
+var (
+	ErrMalformed = errors.New("auth: malformed token")
+	ErrSignature = errors.New("auth: bad token signature")
+	ErrExpired   = errors.New("auth: token expired")
+	ErrRevoked   = errors.New("auth: token revoked")
+)
+
+// MinSecretLen is the shortest hmac key accepted, shorter keys can be brute forced.
+const MinSecretLen = 16
+
+// Claims is what a session token says about the player.
+type Claims struct {
+	Id       int64  `json:"jti,string"` // token id, used for revocation
+	Uid      uint64 `json:"uid,string"`
+	Device   string `json:"dev"`
+	IssuedAt int64  `json:"iat"` // unix seconds
+	Expire   int64  `json:"exp"` // unix seconds
+
+	Roles []string `json:"roles,omitempty"`
+}
+
+// HasRole reports whether the session has any of roles.
+func (c *Claims) HasRole(roles ...string) bool {
+	for _, want := range roles {
+		for _, have := range c.Roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *Claims) ExpireTime() time.Time {
+	return time.Unix(c.Expire, 0)
+}
+
+// Signer issues and verifies tokens of the form base64(claims json).base64(hmac-sha256).
+type Signer struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+func NewSigner(secret []byte, ttl time.Duration) (*Signer, error) {
+	if len(secret) < MinSecretLen {
+		return nil, errors.New("auth: secret shorter than 16 bytes")
+	}
+	if ttl <= 0 {
+		return nil, errors.New("auth: ttl must be positive")
+	}
+	return &Signer{secret: secret, ttl: ttl, now: time.Now}, nil
+}
+
+func (s *Signer) TTL() time.Duration {
+	return s.ttl
+}
+
+// Issue signs a new token of uid on device with roles, valid for the signer ttl.
+func (s *Signer) Issue(uid uint64, device string, roles ...string) (string, *Claims, error) {
+	now := s.now()
+	claims := &Claims{
+		Id:       idgen.Next(),
+		Uid:      uid,
+		Device:   device,
+		IssuedAt: now.Unix(),
+		Expire:   now.Add(s.ttl).Unix(),
+		Roles:    roles,
+	}
+	token, err := s.Sign(claims)
+	return token, claims, err
+}
+
+func (s *Signer) Sign(claims *Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(s.mac(payload)), nil
+}
+
+func (s *Signer) mac(payload []byte) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+// Verify checks the signature and expiry of token, revocation is up to the caller.
+func (s *Signer) Verify(token string) (*Claims, error) {
+	payloadPart, sigPart, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrMalformed
+	}
+	enc := base64.RawURLEncoding
+	payload, err := enc.DecodeString(payloadPart)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	sig, err := enc.DecodeString(sigPart)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if !hmac.Equal(sig, s.mac(payload)) {
+		return nil, ErrSignature
+	}
+
+	claims := &Claims{}
+	if err = json.Unmarshal(payload, claims); err != nil {
+		return nil, ErrMalformed
+	}
+	if s.now().Unix() >= claims.Expire {
+		return claims, ErrExpired
+	}
+	return claims, nil
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSigner(t *testing.T) *Signer {
+	s, err := NewSigner([]byte("0123456789abcdef"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestIssueVerify(t *testing.T) {
+	s := newTestSigner(t)
+	token, claims, err := s.Issue(10001, "ios-abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Uid != 10001 || got.Device != "ios-abc" || got.Id != claims.Id || got.Expire != claims.Expire {
+		t.Errorf("unexpected claims %+v", got)
+	}
+
+	other, _ := NewSigner([]byte("fedcba9876543210"), time.Hour)
+	if _, err = other.Verify(token); err != ErrSignature {
+		t.Errorf("expect signature error with other secret, got %v", err)
+	}
+	payload, sig, _ := strings.Cut(token, ".")
+	if _, err = s.Verify(payload + "x." + sig); err == nil {
+		t.Error("expect tampered payload rejected")
+	}
+	if _, err = s.Verify("garbage"); err != ErrMalformed {
+		t.Errorf("expect malformed, got %v", err)
+	}
+}
+
+func TestVerifyExpired(t *testing.T) {
+	s := newTestSigner(t)
+	token, _, _ := s.Issue(10001, "web")
+
+	s.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if claims, err := s.Verify(token); err != ErrExpired || claims.Uid != 10001 {
+		t.Errorf("expect expired with claims, got %v %v", claims, err)
+	}
+}
+
+func TestRevocation(t *testing.T) {
+	s := newTestSigner(t)
+	r := NewRevocation(time.Hour)
+	_, a, _ := s.Issue(1, "web")
+	_, b, _ := s.Issue(1, "ios")
+	_, c, _ := s.Issue(2, "web")
+
+	r.Revoke(a)
+	if !r.Revoked(a) || r.Revoked(b) {
+		t.Error("expect only a revoked")
+	}
+
+	r.RevokeUid(1)
+	_, d, _ := s.Issue(1, "web")
+	if !r.Revoked(b) || r.Revoked(c) || r.Revoked(d) {
+		t.Error("expect tokens of uid 1 issued before revoked, later ones kept")
+	}
+}
@@ -91,8 +91,10 @@ func (p *byteBufferPool) take(size int) *[]byte {
 		b := newBytes(size)
 		return &b
 	}
+	bytesStats.gets.Add(1)
 	v := p.pool[slot].pool.Get()
 	if v == nil {
+		bytesStats.misses.Add(1)
 		b := newBytes(p.pool[slot].defaultSize)
 		b = b[0:size]
 		return &b
@@ -115,6 +117,7 @@ func (p *byteBufferPool) give(buf *[]byte) {
 	if size != int(p.pool[slot].defaultSize) {
 		return
 	}
+	bytesStats.puts.Add(1)
 	p.pool[slot].pool.Put(buf)
 }
 
@@ -31,8 +31,10 @@ type IoBufferPool struct {
 
 // take returns IoBuffer from IoBufferPool
 func (p *IoBufferPool) take(size int) (buf IoBuffer) {
+	ioStats.gets.Add(1)
 	v := p.pool.Get()
 	if v == nil {
+		ioStats.misses.Add(1)
 		buf = NewIoBuffer(size)
 	} else {
 		buf = v.(IoBuffer)
@@ -44,6 +46,7 @@ func (p *IoBufferPool) take(size int) (buf IoBuffer) {
 
 // give returns IoBuffer to IoBufferPool
 func (p *IoBufferPool) give(buf IoBuffer) {
+	ioStats.puts.Add(1)
 	buf.Free()
 	p.pool.Put(buf)
 }
@@ -0,0 +1,36 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:53
+@Description: gets, puts and misses of the buffer pools
+*/
+
+package buffer
+
+import "sync/atomic"
+
+// PoolStats counts pool use since start, a miss is a get that had to allocate.
+type PoolStats struct {
+	Gets   int64
+	Puts   int64
+	Misses int64
+}
+
+type poolCounters struct {
+	gets, puts, misses atomic.Int64
+}
+
+func (c *poolCounters) load() PoolStats {
+	return PoolStats{Gets: c.gets.Load(), Puts: c.puts.Load(), Misses: c.misses.Load()}
+}
+
+var ioStats, bytesStats poolCounters
+
+// IoBufferPoolStats returns the counters of GetIoBuffer/PutIoBuffer.
+func IoBufferPoolStats() PoolStats {
+	return ioStats.load()
+}
+
+// BytesPoolStats returns the counters of GetBytes/PutBytes, sizes above the largest slot are not pooled and not counted.
+func BytesPoolStats() PoolStats {
+	return bytesStats.load()
+}
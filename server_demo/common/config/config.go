@@ -0,0 +1,302 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:37
+@Description: ini config with env overrides
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/auth"
+	"github.com/Xbzzy/client_demo/server_demo/common/idgen"
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"go.uber.org/zap/zapcore"
+)
+
+// EnvPrefix is prepended to env overrides, e.g. DEMO_LOG_LOG_LEVEL=info or DEMO_SERVER_LISTEN_ADDR=:6000.
+const EnvPrefix = "DEMO"
+
+type ServerConfig struct {
+	ListenAddr        string        `ini:"listen_addr"`
+	NodeId            int64         `ini:"node_id"` // 多实例部署时各不相同, 用于生成请求 id
+	ReadTimeout       time.Duration `ini:"read_timeout"`
+	ReadHeaderTimeout time.Duration `ini:"read_header_timeout"`
+	WriteTimeout      time.Duration `ini:"write_timeout"`
+	IdleTimeout       time.Duration `ini:"idle_timeout"`
+	ShutdownTimeout   time.Duration `ini:"shutdown_timeout"` // 优雅退出最长等待
+	DrainDelay        time.Duration `ini:"drain_delay"`      // 收到退出信号后 readyz 先失败, 等这么久再关监听
+	MaxHeaderBytes    int           `ini:"max_header_bytes"`
+	MaxBodyBytes      int64         `ini:"max_body_bytes"`    // 请求体默认上限
+	SlowThreshold     time.Duration `ini:"slow_threshold"`    // 超过则打慢请求日志, 0 关闭
+	CompressMinSize   int           `ini:"compress_min_size"` // 响应超过则 gzip/deflate 压缩, 0 关闭
+}
+
+type AdminConfig struct {
+	ListenAddr string `ini:"listen_addr"` // 管理端口, 为空不启动
+}
+
+// RateLimitConfig is requests per second and burst per key, a rate of 0 disables the limiter.
+type RateLimitConfig struct {
+	IpRate   float64 `ini:"ip_rate"`
+	IpBurst  int     `ini:"ip_burst"`
+	UidRate  float64 `ini:"uid_rate"`
+	UidBurst int     `ini:"uid_burst"`
+}
+
+// CorsConfig lists the browser origins allowed to call the api, empty origins disables cors.
+type CorsConfig struct {
+	AllowOrigins     []string      `ini:"allow_origins"`
+	AllowMethods     []string      `ini:"allow_methods"`
+	AllowHeaders     []string      `ini:"allow_headers"`
+	AllowCredentials bool          `ini:"allow_credentials"`
+	MaxAge           time.Duration `ini:"max_age"`
+}
+
+// StaticConfig mounts react builds, each entry is prefix=dir like /game=../react_demo/tmp_game/build.
+type StaticConfig struct {
+	Mounts []string `ini:"mounts"`
+}
+
+// AuthConfig signs player session tokens, an empty secret makes a random one so sessions end on restart.
+type AuthConfig struct {
+	Secret   string        `ini:"secret" secret:"true"`
+	TokenTTL time.Duration `ini:"token_ttl"`
+
+	// staff accounts as name:sha256hex of the password
+	Operators []string `ini:"operators" secret:"true"`
+	ShopStaff []string `ini:"shop_staff" secret:"true"`
+}
+
+// Accounts builds the staff accounts with their roles.
+func (c *AuthConfig) Accounts() (auth.Accounts, error) {
+	accounts := make(auth.Accounts)
+	if err := accounts.Add(auth.RoleOperator, c.Operators...); err != nil {
+		return nil, err
+	}
+	if err := accounts.Add(auth.RoleShop, c.ShopStaff...); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// GameConfig tunes the tic-tac-toe rooms.
+type GameConfig struct {
+	RoomIdleTimeout time.Duration `ini:"room_idle_timeout"`
+	MaxSpectators   int           `ini:"max_spectators"`
+	MaxRoomsPerUid  int           `ini:"max_rooms_per_uid"`
+}
+
+// GatewayConfig is the tcp listener of cocos native clients.
+type GatewayConfig struct {
+	ListenAddr   string        `ini:"listen_addr"` // 为空不启动
+	MaxFrameSize int           `ini:"max_frame_size"`
+	IdleTimeout  time.Duration `ini:"idle_timeout"`
+	AuthTimeout  time.Duration `ini:"auth_timeout"`
+	MaxConns     int           `ini:"max_conns"`
+}
+
+// Config is the whole ini file, every field is one [section].
+type Config struct {
+	Log    simplelog.LogConfig `ini:"log"`
+	Server ServerConfig        `ini:"server"`
+	Admin  AdminConfig         `ini:"admin"`
+
+	RateLimit RateLimitConfig `ini:"ratelimit"`
+	Cors      CorsConfig      `ini:"cors"`
+	Static    StaticConfig    `ini:"static"`
+	Auth      AuthConfig      `ini:"auth"`
+	Game      GameConfig      `ini:"game"`
+	Gateway   GatewayConfig   `ini:"gateway"`
+}
+
+// Default returns the settings main used to hard-code.
+func Default() *Config {
+	return &Config{
+		Log: simplelog.LogConfig{
+			LogDir:     "./",
+			LogName:    "output",
+			LogLevel:   "debug",
+			Minute:     1,
+			MaxAge:     7,
+			WithCaller: true,
+		},
+		Server: ServerConfig{
+			ListenAddr:        ":5999",
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      15 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			ShutdownTimeout:   15 * time.Second,
+			MaxHeaderBytes:    1 << 20,
+			MaxBodyBytes:      1 << 20,
+			SlowThreshold:     500 * time.Millisecond,
+			CompressMinSize:   1 << 10,
+		},
+		Admin: AdminConfig{
+			ListenAddr: "127.0.0.1:6999",
+		},
+		Auth: AuthConfig{
+			TokenTTL: 7 * 24 * time.Hour,
+		},
+		Game: GameConfig{
+			RoomIdleTimeout: 10 * time.Minute,
+			MaxSpectators:   8,
+			MaxRoomsPerUid:  3,
+		},
+		Gateway: GatewayConfig{
+			ListenAddr:   ":5998",
+			MaxFrameSize: 64 << 10,
+			IdleTimeout:  60 * time.Second,
+			AuthTimeout:  10 * time.Second,
+			MaxConns:     10000,
+		},
+		RateLimit: RateLimitConfig{
+			IpRate:   20,
+			IpBurst:  40,
+			UidRate:  10,
+			UidBurst: 20,
+		},
+	}
+}
+
+// Load reads path on top of Default, applies env overrides and validates the result.
+// An empty path only uses defaults and env.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		file, order, err := parseIni(f)
+		if err != nil {
+			return nil, fmt.Errorf("config %s: %w", path, err)
+		}
+		if err = cfg.apply(file, order); err != nil {
+			return nil, fmt.Errorf("config %s: %w", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) apply(file iniFile, order []iniLine) error {
+	sections := sectionFields(reflect.ValueOf(c).Elem())
+	for _, l := range order {
+		section, ok := sections[l.section]
+		if !ok {
+			return fmt.Errorf("line %d: unknown section [%s]", l.line, l.section)
+		}
+		field, ok := sectionFields(section)[l.key]
+		if !ok {
+			return fmt.Errorf("line %d: unknown key %s in [%s]", l.line, l.key, l.section)
+		}
+		if err := setField(field, file[l.section][l.key]); err != nil {
+			return fmt.Errorf("line %d: %s: %v", l.line, l.key, err)
+		}
+	}
+	return nil
+}
+
+// EnvName returns the env var overriding key in section.
+func EnvName(section, key string) string {
+	return strings.ToUpper(EnvPrefix + "_" + section + "_" + key)
+}
+
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	for name, section := range sectionFields(reflect.ValueOf(c).Elem()) {
+		for key, field := range sectionFields(section) {
+			env := EnvName(name, key)
+			value, ok := lookup(env)
+			if !ok {
+				continue
+			}
+			if err := setField(field, value); err != nil {
+				return fmt.Errorf("env %s: %v", env, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	_, err := zapcore.ParseLevel(c.Log.LogLevel)
+	check(err == nil, "log.log_level %q is not a zap level", c.Log.LogLevel)
+	check(c.Log.ToStdOut || c.Log.LogDir != "", "log.log_dir is empty")
+	check(c.Log.ToStdOut || c.Log.LogName != "", "log.log_name is empty")
+	check(c.Log.Minute >= 0 && c.Log.Minute <= 60, "log.minute %d out of [0, 60]", c.Log.Minute)
+	check(c.Log.MaxAge >= 0, "log.max_age %d is negative", c.Log.MaxAge)
+	check(c.Log.MaxBackups >= 0, "log.max_backups %d is negative", c.Log.MaxBackups)
+
+	_, _, err = net.SplitHostPort(c.Server.ListenAddr)
+	check(err == nil, "server.listen_addr %q: %v", c.Server.ListenAddr, err)
+	check(c.Server.NodeId >= 0 && c.Server.NodeId <= idgen.MaxNode, "server.node_id %d out of [0, %d]", c.Server.NodeId, idgen.MaxNode)
+	check(c.Server.ReadTimeout >= 0, "server.read_timeout is negative")
+	check(c.Server.ReadHeaderTimeout >= 0, "server.read_header_timeout is negative")
+	check(c.Server.WriteTimeout >= 0, "server.write_timeout is negative")
+	check(c.Server.IdleTimeout >= 0, "server.idle_timeout is negative")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+	check(c.Server.DrainDelay >= 0, "server.drain_delay is negative")
+	check(c.Server.MaxHeaderBytes >= 0, "server.max_header_bytes is negative")
+	check(c.Server.MaxBodyBytes >= 0, "server.max_body_bytes is negative")
+	check(c.Server.SlowThreshold >= 0, "server.slow_threshold is negative")
+	check(c.Server.CompressMinSize >= 0, "server.compress_min_size is negative")
+
+	if c.Admin.ListenAddr != "" {
+		_, _, err = net.SplitHostPort(c.Admin.ListenAddr)
+		check(err == nil, "admin.listen_addr %q: %v", c.Admin.ListenAddr, err)
+	}
+
+	check(c.RateLimit.IpRate >= 0 && c.RateLimit.UidRate >= 0, "ratelimit rate is negative")
+	check(c.RateLimit.IpBurst >= 0 && c.RateLimit.UidBurst >= 0, "ratelimit burst is negative")
+	check(c.Cors.MaxAge >= 0, "cors.max_age is negative")
+	for _, origin := range c.Cors.AllowOrigins {
+		check(origin == "*" || strings.HasPrefix(origin, "http://") || strings.HasPrefix(origin, "https://"),
+			"cors.allow_origins %q must be * or scheme://host[:port]", origin)
+	}
+	for _, mount := range c.Static.Mounts {
+		prefix, dir, ok := strings.Cut(mount, "=")
+		check(ok && strings.HasPrefix(prefix, "/") && dir != "", "static.mounts %q must be /prefix=dir", mount)
+	}
+	check(c.Auth.Secret == "" || len(c.Auth.Secret) >= auth.MinSecretLen, "auth.secret shorter than %d bytes", auth.MinSecretLen)
+	check(c.Auth.TokenTTL > 0, "auth.token_ttl must be positive")
+	if _, err = c.Auth.Accounts(); err != nil {
+		errs = append(errs, err)
+	}
+	check(c.Game.RoomIdleTimeout > 0, "game.room_idle_timeout must be positive")
+	check(c.Game.MaxSpectators >= 0, "game.max_spectators is negative")
+	check(c.Game.MaxRoomsPerUid >= 0, "game.max_rooms_per_uid is negative")
+	if c.Gateway.ListenAddr != "" {
+		_, _, err = net.SplitHostPort(c.Gateway.ListenAddr)
+		check(err == nil, "gateway.listen_addr %q: %v", c.Gateway.ListenAddr, err)
+	}
+	check(c.Gateway.MaxFrameSize > 0, "gateway.max_frame_size must be positive")
+	check(c.Gateway.IdleTimeout > 0, "gateway.idle_timeout must be positive")
+	check(c.Gateway.AuthTimeout > 0, "gateway.auth_timeout must be positive")
+	check(c.Gateway.MaxConns > 0, "gateway.max_conns must be positive")
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeIni(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "server.ini")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadIni(t *testing.T) {
+	path := writeIni(t, `
+; comment
+[log]
+log_level = info
+log_name = "game server"
+minute = 5
+
+[server]
+listen_addr = 127.0.0.1:6000
+read_timeout = 3s
+max_body_bytes = 4096
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Log.LogLevel != "info" || cfg.Log.LogName != "game server" || cfg.Log.Minute != 5 {
+		t.Errorf("unexpected log config %+v", cfg.Log)
+	}
+	// untouched keys keep defaults
+	if cfg.Log.MaxAge != 7 || !cfg.Log.WithCaller {
+		t.Errorf("expect defaults kept, got %+v", cfg.Log)
+	}
+	if cfg.Server.ListenAddr != "127.0.0.1:6000" || cfg.Server.ReadTimeout != 3*time.Second || cfg.Server.MaxBodyBytes != 4096 {
+		t.Errorf("unexpected server config %+v", cfg.Server)
+	}
+}
+
+func TestLoadEnvOverride(t *testing.T) {
+	path := writeIni(t, "[log]\nlog_level = info\n")
+	t.Setenv("DEMO_LOG_LOG_LEVEL", "warn")
+	t.Setenv("DEMO_SERVER_LISTEN_ADDR", ":7000")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Log.LogLevel != "warn" || cfg.Server.ListenAddr != ":7000" {
+		t.Errorf("expect env override, got %s %s", cfg.Log.LogLevel, cfg.Server.ListenAddr)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	cases := map[string]string{
+		"[log]\nlog_levle = info\n":         "unknown key",
+		"[db]\nhost = x\n":                  "unknown section",
+		"[log]\nminute = abc\n":             "minute",
+		"[log]\nlog_level = loud\n":         "log_level",
+		"[server]\nlisten_addr = 5999\n":    "listen_addr",
+		"[server]\nshutdown_timeout = 0s\n": "shutdown_timeout",
+		"[log\n":                            "bad section",
+		"[static]\nmounts = game=./build\n": "static.mounts",
+	}
+	for content, expect := range cases {
+		_, err := Load(writeIni(t, content))
+		if err == nil || !strings.Contains(err.Error(), expect) {
+			t.Errorf("%q: expect error containing %q, got %v", content, expect, err)
+		}
+	}
+}
+
+func TestExampleIniIsValid(t *testing.T) {
+	if _, err := Load("../../server.ini"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,141 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:37
+@Description: ini file decoding into tagged config structs
+*/
+
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// iniFile is section -> key -> value, keys outside any section live in "".
+type iniFile map[string]map[string]string
+
+// iniLine remembers where a key came from for error messages.
+type iniLine struct {
+	section, key string
+	line         int
+}
+
+func parseIni(r io.Reader) (iniFile, []iniLine, error) {
+	file := iniFile{"": {}}
+	var order []iniLine
+	section := ""
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' {
+			if line[len(line)-1] != ']' {
+				return nil, nil, fmt.Errorf("line %d: bad section %q", n, line)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if _, ok := file[section]; !ok {
+				file[section] = map[string]string{}
+			}
+			continue
+		}
+
+		idx := strings.IndexByte(line, '=')
+		if idx <= 0 {
+			return nil, nil, fmt.Errorf("line %d: expect key = value, got %q", n, line)
+		}
+		key := strings.TrimSpace(line[:idx])
+		value := strings.TrimSpace(line[idx+1:])
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			value = unquoted
+		}
+		file[section][key] = value
+		order = append(order, iniLine{section: section, key: key, line: n})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return file, order, nil
+}
+
+// sectionFields maps the ini keys of a struct to its fields.
+func sectionFields(v reflect.Value) map[string]reflect.Value {
+	fields := make(map[string]reflect.Value)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("ini")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		fields[tag] = v.Field(i)
+	}
+	return fields
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setField(field reflect.Value, value string) error {
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported slice type %s", field.Type())
+		}
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		field.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:38
+@Description: live reload of the config on SIGHUP
+*/
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"go.uber.org/zap"
+)
+
+// Change is one key that differs between two configs.
+type Change struct {
+	Key      string // section.key
+	Old, New string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Key, c.Old, c.New)
+}
+
+// Diff returns the keys whose values differ, in struct order. Fields tagged secret:"true" are masked.
+func Diff(old, new *Config) []Change {
+	var changes []Change
+	eachField(old, new, func(key string, f reflect.StructField, o, n reflect.Value) {
+		if reflect.DeepEqual(o.Interface(), n.Interface()) {
+			return
+		}
+		c := Change{Key: key, Old: fmt.Sprint(o.Interface()), New: fmt.Sprint(n.Interface())}
+		if f.Tag.Get("secret") == "true" {
+			c.Old, c.New = "******", "******"
+		}
+		changes = append(changes, c)
+	})
+	return changes
+}
+
+func eachField(a, b *Config, fn func(key string, f reflect.StructField, fa, fb reflect.Value)) {
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	t := va.Type()
+	for i := 0; i < t.NumField(); i++ {
+		section := t.Field(i).Tag.Get("ini")
+		if section == "" || section == "-" {
+			continue
+		}
+		sa, sb := va.Field(i), vb.Field(i)
+		st := sa.Type()
+		for j := 0; j < st.NumField(); j++ {
+			key := st.Field(j).Tag.Get("ini")
+			if key == "" || key == "-" {
+				continue
+			}
+			fn(section+"."+key, st.Field(j), sa.Field(j), sb.Field(j))
+		}
+	}
+}
+
+type applier struct {
+	keys []string
+	fn   func(cfg *Config) error
+}
+
+// Reloader re-reads the config file and applies the keys that can change live,
+// every other changed key keeps its running value until restart.
+type Reloader struct {
+	path string
+
+	mu       sync.Mutex
+	current  *Config
+	appliers []applier
+}
+
+func NewReloader(path string, current *Config) *Reloader {
+	return &Reloader{path: path, current: current}
+}
+
+// OnChange registers fn for keys ("log.log_level"), fn gets the new config when any of them changed.
+func (r *Reloader) OnChange(fn func(cfg *Config) error, keys ...string) {
+	r.mu.Lock()
+	r.appliers = append(r.appliers, applier{keys: keys, fn: fn})
+	r.mu.Unlock()
+}
+
+// Current returns the running config, callers must not modify it.
+func (r *Reloader) Current() *Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Reload loads the file again and applies it, the outcome is logged through l.
+func (r *Reloader) Reload(l simplelog.LogI) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	loaded, err := Load(r.path)
+	if err != nil {
+		l.WarnWF("config reload fail", zap.String("path", r.path), zap.Error(err))
+		return err
+	}
+
+	changes := Diff(r.current, loaded)
+	if len(changes) == 0 {
+		l.InfoWF("config reload no change", zap.String("path", r.path))
+		return nil
+	}
+
+	changed := make(map[string]bool, len(changes))
+	for _, c := range changes {
+		changed[c.Key] = true
+	}
+
+	live := make(map[string]bool)
+	failed := make(map[string]bool)
+	for _, a := range r.appliers {
+		hit := false
+		for _, key := range a.keys {
+			if changed[key] {
+				hit = true
+				break
+			}
+		}
+		if !hit {
+			continue
+		}
+		if err := a.fn(loaded); err != nil {
+			l.WarnWF("config reload apply fail", zap.Strings("keys", a.keys), zap.Error(err))
+			for _, key := range a.keys {
+				failed[key] = true
+			}
+			continue
+		}
+		for _, key := range a.keys {
+			live[key] = true
+		}
+	}
+
+	// keys that were not applied keep the running value, so Current matches what the process uses
+	running := *loaded
+	var applied, restart []string
+	eachField(&running, r.current, func(key string, _ reflect.StructField, fr, fc reflect.Value) {
+		if !changed[key] {
+			return
+		}
+		if live[key] && !failed[key] {
+			return
+		}
+		fr.Set(fc)
+	})
+	for _, c := range changes {
+		if live[c.Key] && !failed[c.Key] {
+			applied = append(applied, c.String())
+		} else {
+			restart = append(restart, c.String())
+		}
+	}
+	r.current = &running
+
+	l.InfoWF("config reloaded", zap.String("path", r.path), zap.Strings("applied", applied),
+		zap.Strings("needRestart", restart))
+	return nil
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+)
+
+func TestDiff(t *testing.T) {
+	old, new := Default(), Default()
+	new.Log.LogLevel = "info"
+	new.Server.ListenAddr = ":6000"
+	new.Auth.Secret = "0123456789abcdef"
+
+	changes := Diff(old, new)
+	if len(changes) != 3 {
+		t.Fatalf("expect 3 changes, got %v", changes)
+	}
+	if changes[0].String() != "log.log_level: debug -> info" || changes[1].Key != "server.listen_addr" {
+		t.Errorf("unexpected changes %v", changes)
+	}
+	if changes[2].String() != "auth.secret: ****** -> ******" {
+		t.Errorf("expect secret masked, got %s", changes[2])
+	}
+}
+
+func TestReloaderAppliesLiveKeysOnly(t *testing.T) {
+	path := writeIni(t, "[log]\nlog_level = debug\n")
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReloader(path, cfg)
+	var level string
+	r.OnChange(func(c *Config) error {
+		level = c.Log.LogLevel
+		return nil
+	}, "log.log_level")
+	r.OnChange(func(c *Config) error {
+		return errors.New("cannot apply")
+	}, "log.minute")
+
+	if err = os.WriteFile(path, []byte("[log]\nlog_level = warn\nminute = 10\n[server]\nlisten_addr = :6000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = r.Reload(&simplelog.ZapLog{}); err != nil {
+		t.Fatal(err)
+	}
+
+	cur := r.Current()
+	if level != "warn" || cur.Log.LogLevel != "warn" {
+		t.Errorf("expect log level applied, got %s %s", level, cur.Log.LogLevel)
+	}
+	// failed and restart-only keys keep the running value
+	if cur.Log.Minute != cfg.Log.Minute || cur.Server.ListenAddr != cfg.Server.ListenAddr {
+		t.Errorf("expect running values kept, got minute %d addr %s", cur.Log.Minute, cur.Server.ListenAddr)
+	}
+}
+
+func TestReloaderKeepsConfigOnError(t *testing.T) {
+	path := writeIni(t, "[log]\nlog_level = info\n")
+	cfg, _ := Load(path)
+	r := NewReloader(path, cfg)
+
+	_ = os.WriteFile(path, []byte("[log]\nlog_level = loud\n"), 0644)
+	if err := r.Reload(&simplelog.ZapLog{}); err == nil {
+		t.Fatal("expect reload error")
+	}
+	if r.Current() != cfg {
+		t.Error("expect config unchanged")
+	}
+}
@@ -0,0 +1,97 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:41
+@Description: snowflake ids for requests, sessions and rooms
+*/
+
+package idgen
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// snowflake layout: 1 bit unused | 41 bits ms since epoch | 10 bits node | 12 bits sequence
+const (
+	nodeBits = 10
+	seqBits  = 12
+
+	MaxNode = 1<<nodeBits - 1
+	maxSeq  = 1<<seqBits - 1
+)
+
+// epoch is 2024-01-01 UTC, 41 bits of ms last until about 2093.
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+
+// Snowflake mints unique ids that sort by creation time, ids of different nodes never collide.
+type Snowflake struct {
+	mu     sync.Mutex
+	node   int64
+	lastMs int64
+	seq    int64
+}
+
+func NewSnowflake(node int64) (*Snowflake, error) {
+	if node < 0 || node > MaxNode {
+		return nil, fmt.Errorf("idgen: node %d out of [0, %d]", node, MaxNode)
+	}
+	return &Snowflake{node: node}, nil
+}
+
+func (s *Snowflake) Next() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UnixMilli() - epoch
+	if now > s.lastMs {
+		s.lastMs = now
+		s.seq = 0
+	} else {
+		// same ms, or the clock went back: keep counting on lastMs so ids stay increasing
+		s.seq++
+		if s.seq > maxSeq {
+			s.lastMs++
+			s.seq = 0
+		}
+	}
+	return s.lastMs<<(nodeBits+seqBits) | s.node<<seqBits | s.seq
+}
+
+// Time returns when id was minted.
+func Time(id int64) time.Time {
+	return time.UnixMilli(id>>(nodeBits+seqBits) + epoch)
+}
+
+// Node returns the node that minted id.
+func Node(id int64) int64 {
+	return id >> seqBits & MaxNode
+}
+
+var defaultGen atomic.Pointer[Snowflake]
+
+func init() {
+	gen, _ := NewSnowflake(0)
+	defaultGen.Store(gen)
+}
+
+// SetNode sets the node of the default generator, call it once at start. It is safe against
+// concurrent Next calls, the new generator goes on from the last id of the old one.
+func SetNode(node int64) error {
+	gen, err := NewSnowflake(node)
+	if err != nil {
+		return err
+	}
+	old := defaultGen.Load()
+	old.mu.Lock()
+	gen.lastMs, gen.seq = old.lastMs, old.seq
+	defaultGen.Store(gen)
+	old.mu.Unlock()
+	return nil
+}
+
+// Next returns an id from the default generator.
+func Next() int64 {
+	return defaultGen.Load().Next()
+}
@@ -0,0 +1,85 @@
+package idgen
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSnowflakeUniqueAndSorted(t *testing.T) {
+	s, err := NewSnowflake(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	last := int64(0)
+	for i := 0; i < 100000; i++ {
+		id := s.Next()
+		if id <= last {
+			t.Fatalf("expect increasing ids, got %d after %d", id, last)
+		}
+		last = id
+	}
+
+	if Node(last) != 7 {
+		t.Errorf("expect node 7, got %d", Node(last))
+	}
+	if d := time.Since(Time(last)); d < -time.Second || d > time.Minute {
+		t.Errorf("unexpected id time %v", Time(last))
+	}
+}
+
+func TestSnowflakeConcurrent(t *testing.T) {
+	s, _ := NewSnowflake(1)
+	var (
+		mu   sync.Mutex
+		seen = make(map[int64]bool)
+		wg   sync.WaitGroup
+	)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids := make([]int64, 0, 5000)
+			for i := 0; i < 5000; i++ {
+				ids = append(ids, s.Next())
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, id := range ids {
+				if seen[id] {
+					t.Errorf("duplicate id %d", id)
+				}
+				seen[id] = true
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSnowflakeBadNode(t *testing.T) {
+	if _, err := NewSnowflake(MaxNode + 1); err == nil {
+		t.Error("expect error for node out of range")
+	}
+	if err := SetNode(-1); err == nil {
+		t.Error("expect error for negative node")
+	}
+}
+
+func TestSetNodeWhileMinting(t *testing.T) {
+	defer SetNode(0)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			Next()
+		}
+	}()
+	if err := SetNode(3); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if id := Next(); Node(id) != 3 {
+		t.Errorf("expect node 3, got %d", Node(id))
+	}
+}
@@ -0,0 +1,359 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:53
+@Description: counters, gauges and histograms in the prometheus text format
+*/
+
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// DefBuckets are latency buckets in seconds, the same as the prometheus client.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Default is the registry fed by the router, the buffer pools and the log file.
+var Default = NewRegistry()
+
+// atomicFloat is a float64 updated with compare and swap.
+type atomicFloat struct {
+	bits atomic.Uint64
+}
+
+func (f *atomicFloat) Add(v float64) {
+	for {
+		old := f.bits.Load()
+		if f.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) Set(v float64) {
+	f.bits.Store(math.Float64bits(v))
+}
+
+func (f *atomicFloat) Load() float64 {
+	return math.Float64frombits(f.bits.Load())
+}
+
+// Counter only goes up.
+type Counter struct {
+	v atomicFloat
+}
+
+func (c *Counter) Inc() {
+	c.v.Add(1)
+}
+
+// Add panics on a negative v, use a Gauge for values going down.
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.v.Add(v)
+}
+
+func (c *Counter) Value() float64 {
+	return c.v.Load()
+}
+
+type Gauge struct {
+	v atomicFloat
+}
+
+func (g *Gauge) Set(v float64) {
+	g.v.Set(v)
+}
+
+func (g *Gauge) Add(v float64) {
+	g.v.Add(v)
+}
+
+func (g *Gauge) Value() float64 {
+	return g.v.Load()
+}
+
+// Histogram counts observations into buckets of upper bounds.
+type Histogram struct {
+	upper  []float64
+	counts []atomic.Uint64 // not cumulative, summed when written
+	count  atomic.Uint64
+	sum    atomicFloat
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{upper: buckets, counts: make([]atomic.Uint64, len(buckets))}
+}
+
+func (h *Histogram) Observe(v float64) {
+	if i := sort.SearchFloat64s(h.upper, v); i < len(h.upper) {
+		h.counts[i].Add(1)
+	}
+	h.count.Add(1)
+	h.sum.Add(v)
+}
+
+// Count returns the number of observations.
+func (h *Histogram) Count() uint64 {
+	return h.count.Load()
+}
+
+// vec holds one series per label values.
+type vec[T any] struct {
+	labels []string
+	newFn  func() T
+
+	mu     sync.RWMutex
+	series map[string]T
+	values map[string][]string
+}
+
+func newVec[T any](labels []string, newFn func() T) *vec[T] {
+	return &vec[T]{labels: labels, newFn: newFn, series: make(map[string]T), values: make(map[string][]string)}
+}
+
+// With returns the series of values, in the order of the label names.
+func (v *vec[T]) With(values ...string) T {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: expect %d label values, got %d", len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	v.mu.RLock()
+	s, ok := v.series[key]
+	v.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s, ok = v.series[key]; !ok {
+		s = v.newFn()
+		v.series[key] = s
+		v.values[key] = append([]string(nil), values...)
+	}
+	return s
+}
+
+// each calls fn on every series sorted by label values.
+func (v *vec[T]) each(fn func(labels string, s T)) {
+	v.mu.RLock()
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	list := make([]T, len(keys))
+	labels := make([]string, len(keys))
+	for i, key := range keys {
+		list[i] = v.series[key]
+		labels[i] = formatLabels(v.labels, v.values[key])
+	}
+	v.mu.RUnlock()
+
+	for i := range list {
+		fn(labels[i], list[i])
+	}
+}
+
+type CounterVec struct{ *vec[*Counter] }
+type GaugeVec struct{ *vec[*Gauge] }
+type HistogramVec struct{ *vec[*Histogram] }
+
+// FuncVec reads its values when scraped, for counters kept elsewhere (pools, log file).
+type FuncVec struct {
+	labels []string
+
+	mu    sync.Mutex
+	funcs []labeledFunc
+}
+
+type labeledFunc struct {
+	labels string
+	fn     func() float64
+}
+
+// Set adds a series read from fn, values are in the order of the label names.
+func (f *FuncVec) Set(fn func() float64, values ...string) {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: expect %d label values, got %d", len(f.labels), len(values)))
+	}
+	f.mu.Lock()
+	f.funcs = append(f.funcs, labeledFunc{labels: formatLabels(f.labels, values), fn: fn})
+	f.mu.Unlock()
+}
+
+type family struct {
+	name, help, typ string
+	write           func(w *bufio.Writer, name string)
+}
+
+// Registry is a set of metric families, names must be unique.
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+	names    map[string]bool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+func (r *Registry) register(f *family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[f.name] {
+		panic("metrics: duplicate metric " + f.name)
+	}
+	r.names[f.name] = true
+	r.families = append(r.families, f)
+}
+
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{newVec(labels, func() *Counter { return &Counter{} })}
+	r.register(&family{name: name, help: help, typ: TypeCounter, write: func(w *bufio.Writer, name string) {
+		v.each(func(labels string, c *Counter) { writeSample(w, name, labels, c.Value()) })
+	}})
+	return v
+}
+
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	v := &GaugeVec{newVec(labels, func() *Gauge { return &Gauge{} })}
+	r.register(&family{name: name, help: help, typ: TypeGauge, write: func(w *bufio.Writer, name string) {
+		v.each(func(labels string, g *Gauge) { writeSample(w, name, labels, g.Value()) })
+	}})
+	return v
+}
+
+// NewHistogramVec uses DefBuckets when buckets is nil, buckets must be sorted.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	v := &HistogramVec{newVec(labels, func() *Histogram { return newHistogram(buckets) })}
+	r.register(&family{name: name, help: help, typ: TypeHistogram, write: func(w *bufio.Writer, name string) {
+		v.each(func(labels string, h *Histogram) { writeHistogram(w, name, labels, h) })
+	}})
+	return v
+}
+
+func (r *Registry) NewCounter(name, help string) *Counter {
+	return r.NewCounterVec(name, help).With()
+}
+
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	return r.NewGaugeVec(name, help).With()
+}
+
+// NewFuncVec registers a family of typ (TypeCounter or TypeGauge) whose series are added with Set.
+func (r *Registry) NewFuncVec(name, help, typ string, labels ...string) *FuncVec {
+	f := &FuncVec{labels: labels}
+	r.register(&family{name: name, help: help, typ: typ, write: func(w *bufio.Writer, name string) {
+		f.mu.Lock()
+		funcs := append([]labeledFunc(nil), f.funcs...)
+		f.mu.Unlock()
+		for _, lf := range funcs {
+			writeSample(w, name, lf.labels, lf.fn())
+		}
+	}})
+	return f
+}
+
+// WriteText writes every family in the prometheus text format 0.0.4, in registration order.
+func (r *Registry) WriteText(out io.Writer) error {
+	r.mu.Lock()
+	families := append([]*family(nil), r.families...)
+	r.mu.Unlock()
+
+	w := bufio.NewWriter(out)
+	for _, f := range families {
+		fmt.Fprintf(w, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.typ)
+		f.write(w, f.name)
+	}
+	return w.Flush()
+}
+
+func writeSample(w *bufio.Writer, name, labels string, v float64) {
+	w.WriteString(name)
+	w.WriteString(labels)
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func writeHistogram(w *bufio.Writer, name, labels string, h *Histogram) {
+	var cumulative uint64
+	for i, upper := range h.upper {
+		cumulative += h.counts[i].Load()
+		writeSample(w, name+"_bucket", withLabel(labels, "le", formatFloat(upper)), float64(cumulative))
+	}
+	count := h.count.Load()
+	writeSample(w, name+"_bucket", withLabel(labels, "le", "+Inf"), float64(count))
+	writeSample(w, name+"_sum", labels, h.sum.Load())
+	writeSample(w, name+"_count", labels, float64(count))
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// formatLabels returns {a="x",b="y"}, or "" without labels.
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// withLabel appends name="value" to formatted labels.
+func withLabel(labels, name, value string) string {
+	pair := name + `="` + value + `"`
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+	return labels[:len(labels)-1] + "," + pair + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounterVec("requests_total", "Requests.", "pattern", "status")
+	requests.With("/room/{id}", "200").Add(2)
+	requests.With(`/a"b`, "500").Inc()
+	r.NewGauge("in_flight", "In flight.").Set(3)
+	latency := r.NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "pattern")
+	latency.With("/a").Observe(0.05)
+	latency.With("/a").Observe(0.5)
+	latency.With("/a").Observe(5)
+	r.NewFuncVec("pool_gets_total", "Gets.", TypeCounter, "pool").Set(func() float64 { return 7 }, "io")
+
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	expect := `# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{pattern="/a\"b",status="500"} 1
+requests_total{pattern="/room/{id}",status="200"} 2
+# HELP in_flight In flight.
+# TYPE in_flight gauge
+in_flight 3
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{pattern="/a",le="0.1"} 1
+latency_seconds_bucket{pattern="/a",le="1"} 2
+latency_seconds_bucket{pattern="/a",le="+Inf"} 3
+latency_seconds_sum{pattern="/a"} 5.55
+latency_seconds_count{pattern="/a"} 3
+# HELP pool_gets_total Gets.
+# TYPE pool_gets_total counter
+pool_gets_total{pool="io"} 7
+`
+	if b.String() != expect {
+		t.Errorf("unexpected text:\n%s", b.String())
+	}
+}
+
+func TestRegistryRejects(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("a_total", "A.")
+
+	for name, fn := range map[string]func(){
+		"duplicate":    func() { r.NewGauge("a_total", "A.") },
+		"label count":  func() { r.NewCounterVec("b_total", "B.", "x").With("1", "2") },
+		"negative add": func() { r.NewCounter("c_total", "C.").Add(-1) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expect panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
@@ -33,6 +33,9 @@ func NewRotateFile(logPath, logFileName string, MaxBackups, MaxAge int, minute i
 		SyncLog:    make(chan buffer.IoBuffer, 40960),
 		newRule:    true,
 		minute:     minute,
+		stopCh:     make(chan struct{}),
+		syncDone:   make(chan struct{}),
+		preDone:    make(chan struct{}),
 	}
 
 	if res.MaxSize == 0 {
@@ -110,6 +113,33 @@ type RotateFile struct {
 	minute  int
 	cTime   int64 //
 	full    bool  //
+
+	closed   bool          // Close 之后丢弃写入
+	stopCh   chan struct{} // 停止 Preopen
+	syncDone chan struct{} // SyncLogFile 写完退出
+	preDone  chan struct{} // Preopen 退出
+
+	bytesWritten atomic.Int64
+	rotations    atomic.Int64 // 切换到新文件成功的次数
+	rotateFails  atomic.Int64 // 新文件没能换上, 继续写旧文件的次数
+	dropped      atomic.Int64 // SyncLog 满了丢掉的日志
+}
+
+// Stats are counters since the file was created.
+type Stats struct {
+	BytesWritten   int64
+	Rotations      int64
+	RotateFailures int64
+	DroppedWrites  int64
+}
+
+func (l *RotateFile) Stats() Stats {
+	return Stats{
+		BytesWritten:   l.bytesWritten.Load(),
+		Rotations:      l.rotations.Load(),
+		RotateFailures: l.rotateFails.Load(),
+		DroppedWrites:  l.dropped.Load(),
+	}
 }
 
 var (
@@ -123,13 +153,21 @@ var (
 	// variable so tests can mock it out and not need to write megabytes of data
 	// to disk.
 	megabyte = 1024 * 1024
+
+	// ErrClosed is returned by Write after Close.
+	ErrClosed = errors.New("rotatefile: closed")
 )
 
 func (l *RotateFile) SyncLogFile() {
+	defer close(l.syncDone)
 	for b := range l.SyncLog {
+		// l.file is switched under mu by switchFile
+		l.mu.Lock()
 		size, _ := b.WriteTo(l.file)
-		_ = buffer.PutIoBuffer(b)
 		l.size += size
+		l.mu.Unlock()
+		_ = buffer.PutIoBuffer(b)
+		l.bytesWritten.Add(size)
 	}
 }
 
@@ -141,6 +179,10 @@ func (l *RotateFile) Write(p []byte) (n int, err error) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	if l.closed {
+		return 0, ErrClosed
+	}
+
 	writeLen := int64(len(p))
 	// 按时间分隔时，
 	if writeLen > l.max() {
@@ -193,6 +235,8 @@ func (l *RotateFile) Write(p []byte) (n int, err error) {
 	select {
 	case l.SyncLog <- b:
 	default:
+		l.dropped.Add(1)
+		_ = buffer.PutIoBuffer(b)
 	}
 
 	//n, err = l.file.Write(p)
@@ -201,10 +245,32 @@ func (l *RotateFile) Write(p []byte) (n int, err error) {
 	return n, err
 }
 
-// Close implements io.Closer, and closes the current logfile.
+// Close implements io.Closer. It stops the Preopen and mill goroutines, waits
+// until every buffer queued on SyncLog is written, and closes the current
+// logfile. Writes after Close return ErrClosed.
 func (l *RotateFile) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	close(l.stopCh)
+	close(l.SyncLog)
+	if l.millCh != nil {
+		close(l.millCh)
+	}
+	l.mu.Unlock()
+
+	<-l.syncDone
+	<-l.preDone
+
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	if l.newFile != nil {
+		_ = l.newFile.Close()
+		l.newFile = nil
+	}
 	return l.close()
 }
 
@@ -226,9 +292,40 @@ func (l *RotateFile) close() error {
 func (l *RotateFile) Rotate() error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+	// rotate only switches to a preopened file, open it now instead of waiting for Preopen
+	if l.newFile == nil {
+		if err := l.preopen(); err != nil {
+			return err
+		}
+	}
 	return l.rotate()
 }
 
+// SetMinute changes the rotation interval, the next rotation happens on the new boundary.
+func (l *RotateFile) SetMinute(minute int) {
+	if minute <= 0 {
+		minute = 30
+	}
+	if minute > 60 {
+		minute = 60
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.minute = minute
+	l.cTime = l.getLastTime()
+}
+
+// Minute returns the rotation interval.
+func (l *RotateFile) Minute() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.minute
+}
+
 // rotate closes the current file, moves it aside with a timestamp in the name,
 // (if it exists), opens a new file with the original filename, and then runs
 // post-rotation processing and removal.
@@ -241,7 +338,7 @@ func (l *RotateFile) rotate() error {
 		return nil
 	}
 
-	fmt.Println("RotateFile rotate begin", l.newFile != nil, l.rotating == 1)
+	fmt.Println("RotateFile rotate begin", l.newFile != nil)
 	go l.switchFile(3)
 
 	// if err := l.close(); err != nil {
@@ -254,10 +351,20 @@ func (l *RotateFile) rotate() error {
 	return nil
 }
 
+// switchFile moves the current file aside and the preopened one in its place. It holds mu
+// like Write and Close, a rotation that ends after Close leaves the closed files alone.
 func (l *RotateFile) switchFile(mask int) {
-	_ = os.MkdirAll(l.dir(), 0755)
 	time.Sleep(time.Millisecond * 500)
 
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	defer atomic.StoreInt32(&l.rotating, 0)
+	if l.closed || l.newFile == nil {
+		fmt.Println("RotateFile switchFile skipped, closed", l.closed)
+		return
+	}
+	_ = os.MkdirAll(l.dir(), 0755)
+
 	name := l.filename()
 	backName := l.backupName(name)
 
@@ -277,9 +384,11 @@ func (l *RotateFile) switchFile(mask int) {
 		dir := filepath.Dir(l.Filename)
 		newName := filepath.Join(dir, preopenName)
 		if err := os.Rename(newName, name); err != nil {
+			// keep writing to the old file, Preopen makes a new one for the next rotation
+			_ = l.newFile.Close()
 			l.newFile = nil
+			l.rotateFails.Add(1)
 			fmt.Println("RotateFile switchFile adjust can't rename log file", newName, name, mask, err)
-			l.switchFile(2)
 			return
 		}
 	}
@@ -287,12 +396,12 @@ func (l *RotateFile) switchFile(mask int) {
 	l.oldFile = l.file
 	l.file = l.newFile
 	l.newFile = nil
+	l.rotations.Add(1)
 	l.size = 0
 	if l.full {
 		l.full = false
 	}
 	l.cTime = l.getLastTime()
-	l.rotating = 0
 
 	if l.oldFile != nil {
 		err := l.oldFile.Close()
@@ -307,29 +416,43 @@ func (l *RotateFile) switchFile(mask int) {
 
 // Preopen 在切分日志之前,把新日志文件创建出来
 func (l *RotateFile) Preopen() {
+	defer close(l.preDone)
 	_ = os.MkdirAll(l.dir(), 0755)
 
 	ticker := time.NewTicker(time.Duration(preopenTicker) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		l.mu.Lock()
+		if !l.closed && l.newFile == nil &&
+			(time.Now().Unix()-l.cTime+preopenTime >= int64(l.minute*60) || l.size+int64(preopenSize*megabyte) > l.max()) {
+			_ = l.preopen()
+		}
+		l.mu.Unlock()
+	}
+}
+
+// preopen creates the file the next rotation switches to, the caller holds mu.
+func (l *RotateFile) preopen() error {
 	mode := os.FileMode(0644)
 	dir := filepath.Dir(l.Filename)
 	newName := filepath.Join(dir, preopenName)
 
-	for range ticker.C {
-		if time.Now().Unix()-l.cTime+preopenTime >= int64(l.minute*60) || l.size+int64(preopenSize*megabyte) > l.max() {
-			if l.newFile != nil {
-				continue
-			}
-
-			f, err := os.OpenFile(newName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
-			if err != nil {
-				fmt.Println("RotateFile Preopen cannot open file", newName, err)
-				continue
-			}
-
-			l.newFile = f
-			fmt.Println("RotateFile Preopen success", newName, l.size, time.Now().Unix()-l.cTime)
-		}
+	f, err := os.OpenFile(newName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		fmt.Println("RotateFile Preopen cannot open file", newName, err)
+		return err
 	}
+
+	l.newFile = f
+	fmt.Println("RotateFile Preopen success", newName, l.size, time.Now().Unix()-l.cTime)
+	return nil
 }
 
 // openNew opens a new log file for writing, moving any old log file out of the
@@ -565,6 +688,9 @@ func (l *RotateFile) millRun() {
 // mill performs post-rotation compression and removal of stale log files,
 // starting the mill goroutine if necessary.
 func (l *RotateFile) mill() {
+	if l.closed {
+		return
+	}
 	l.startMill.Do(func() {
 		l.millCh = make(chan bool, 1)
 		go l.millRun()
@@ -0,0 +1,171 @@
+package rotatefile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond for up to 3s, switchFile waits half a second before it moves files.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for i := 0; i < 300; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("condition not met")
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// backups lists the rotated files of dir, the live and the preopened file excluded.
+func backups(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		if e.Name() != "server.log" && e.Name() != preopenName {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
+
+func TestWriteAndClose(t *testing.T) {
+	dir := t.TempDir()
+	f := NewRotateFile(dir, "server.log", 0, 0, 30)
+	if _, err := f.Write([]byte("line 1\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Close waits for the queued buffers
+	if got := readFile(t, filepath.Join(dir, "server.log")); got != "line 1\n" {
+		t.Errorf("unexpected content %q", got)
+	}
+	if _, err := f.Write([]byte("late\n")); !errors.Is(err, ErrClosed) {
+		t.Errorf("expect ErrClosed after Close, got %v", err)
+	}
+	if err := f.Rotate(); !errors.Is(err, ErrClosed) {
+		t.Errorf("expect Rotate to fail after Close, got %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("expect a second Close to be a no-op, got %v", err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	dir := t.TempDir()
+	f := NewRotateFile(dir, "server.log", 0, 0, 30)
+	defer f.Close()
+
+	_, _ = f.Write([]byte("before\n"))
+	waitFor(t, func() bool { return f.Stats().BytesWritten == 7 })
+	if err := f.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return len(backups(t, dir)) == 1 })
+	// the switch is done once a new rotation may start
+	waitFor(t, func() bool {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.rotating == 0
+	})
+
+	_, _ = f.Write([]byte("after\n"))
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(dir, "server.log")); got != "after\n" {
+		t.Errorf("expect the new file to hold only new lines, got %q", got)
+	}
+	if got := readFile(t, filepath.Join(dir, backups(t, dir)[0])); got != "before\n" {
+		t.Errorf("expect the backup to hold the old lines, got %q", got)
+	}
+	if n := f.Stats().Rotations; n != 1 {
+		t.Errorf("expect 1 rotation, got %d", n)
+	}
+}
+
+func TestRotateFailure(t *testing.T) {
+	dir := t.TempDir()
+	f := NewRotateFile(dir, "server.log", 0, 0, 30)
+	defer f.Close()
+
+	_, _ = f.Write([]byte("before\n"))
+	if err := f.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	// switchFile waits before it moves files, the preopened file is gone by then
+	if err := os.Remove(filepath.Join(dir, preopenName)); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return f.Stats().RotateFailures == 1 })
+	if n := f.Stats().Rotations; n != 0 {
+		t.Errorf("expect no rotation counted, got %d", n)
+	}
+}
+
+// TestCloseDuringRotate is meant for -race, Close runs while switchFile is waiting to move files.
+func TestCloseDuringRotate(t *testing.T) {
+	dir := t.TempDir()
+	f := NewRotateFile(dir, "server.log", 0, 0, 30)
+	_, _ = f.Write([]byte("before\n"))
+	if err := f.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, func() bool {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.rotating == 0
+	})
+	f.mu.Lock()
+	reopened := f.file != nil || f.newFile != nil
+	f.mu.Unlock()
+	if reopened {
+		t.Error("expect no file open after Close")
+	}
+	if names := backups(t, dir); len(names) != 0 {
+		t.Errorf("expect the closed file left in place, got %v", names)
+	}
+	if got := readFile(t, filepath.Join(dir, "server.log")); !strings.Contains(got, "before") {
+		t.Errorf("unexpected content %q", got)
+	}
+}
+
+func TestSetMinute(t *testing.T) {
+	f := NewRotateFile(t.TempDir(), "server.log", 0, 0, 30)
+	defer f.Close()
+
+	for _, c := range []struct{ in, want int }{{5, 5}, {0, 30}, {90, 60}} {
+		f.SetMinute(c.in)
+		if got := f.Minute(); got != c.want {
+			t.Errorf("SetMinute(%d): expect %d, got %d", c.in, c.want, got)
+		}
+	}
+	f.mu.Lock()
+	cTime := f.cTime
+	f.mu.Unlock()
+	if cTime%(60*60) != 0 || cTime > time.Now().Unix() {
+		t.Errorf("expect cTime on the last hour boundary, got %d", cTime)
+	}
+}
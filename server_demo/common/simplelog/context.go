@@ -0,0 +1,39 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:41
+@Description: request logger carried on context.Context
+*/
+
+package simplelog
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type ctxKey struct{}
+
+var defaultLog atomic.Value // LogI
+
+// SetDefault sets the logger FromContext returns when ctx carries none, normally the global logger.
+func SetDefault(l LogI) {
+	defaultLog.Store(&l)
+}
+
+// NewContext returns ctx carrying l, pass the per-request clone so uid and logId go along.
+func NewContext(ctx context.Context, l LogI) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns the logger in ctx, then the default logger, then a logger that drops everything.
+func FromContext(ctx context.Context) LogI {
+	if ctx != nil {
+		if l, ok := ctx.Value(ctxKey{}).(LogI); ok && l != nil {
+			return l
+		}
+	}
+	if l, ok := defaultLog.Load().(*LogI); ok && *l != nil {
+		return *l
+	}
+	return &ZapLog{}
+}
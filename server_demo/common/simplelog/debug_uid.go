@@ -0,0 +1,98 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:39
+@Description: debug level logging for selected uids
+*/
+
+package simplelog
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// debugUids lists the uids whose debug logs are written whatever the log level is,
+// it is shared by a ZapLog and all of its clones.
+type debugUids struct {
+	mu   sync.RWMutex
+	uids map[uint64]time.Time // uid -> expire time, zero means never
+}
+
+func newDebugUids() *debugUids {
+	return &debugUids{uids: make(map[uint64]time.Time)}
+}
+
+func (d *debugUids) has(uid uint64) bool {
+	d.mu.RLock()
+	until, ok := d.uids[uid]
+	d.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	if !until.IsZero() && time.Now().After(until) {
+		d.remove(uid)
+		return false
+	}
+	return true
+}
+
+func (d *debugUids) set(uid uint64, until time.Time) {
+	d.mu.Lock()
+	d.uids[uid] = until
+	d.mu.Unlock()
+}
+
+func (d *debugUids) remove(uid uint64) {
+	d.mu.Lock()
+	delete(d.uids, uid)
+	d.mu.Unlock()
+}
+
+func (d *debugUids) list() map[uint64]time.Time {
+	now := time.Now()
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	out := make(map[uint64]time.Time, len(d.uids))
+	for uid, until := range d.uids {
+		if until.IsZero() || now.Before(until) {
+			out[uid] = until
+		}
+	}
+	return out
+}
+
+// SetDebugUid writes debug logs of uid until the given time, a zero time never expires. Only
+// loggers that already carry uid are affected, lines logged before SetUid follow the log level.
+func (zl *ZapLog) SetDebugUid(uid uint64, until time.Time) {
+	if zl.debugUids != nil {
+		zl.debugUids.set(uid, until)
+	}
+}
+
+func (zl *ZapLog) RemoveDebugUid(uid uint64) {
+	if zl.debugUids != nil {
+		zl.debugUids.remove(uid)
+	}
+}
+
+// DebugUids returns the active uid overrides and their expire time.
+func (zl *ZapLog) DebugUids() map[uint64]time.Time {
+	if zl.debugUids == nil {
+		return nil
+	}
+	return zl.debugUids.list()
+}
+
+// debugLogger picks the always-debug logger for uids in the override list.
+func (zl *ZapLog) debugLogger() *zap.Logger {
+	if zl.uid == 0 || zl.debugLog == nil || zl.zapLogLevel.Enabled(zapcore.DebugLevel) {
+		return zl.log
+	}
+	if zl.debugUids.has(zl.uid) {
+		return zl.debugLog
+	}
+	return zl.log
+}
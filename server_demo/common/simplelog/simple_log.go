@@ -28,13 +28,16 @@ type LogI interface {
 	SetLogLevel(strLevel string)
 	GetLogLevel() string
 	SetLogId(id int64)
+	GetLogId() int64
 	SetUid(uid uint64)
 	GetUid() (uid uint64)
 	Clone() LogI
+	Close() error
 
 	Init(config *LogConfig) bool
 	InfoWF(msg string, fields ...zapcore.Field)
 	WarnWF(msg string, fields ...zapcore.Field)
+	ErrorWF(msg string, fields ...zapcore.Field)
 	DebugWF(msg string, fields ...zapcore.Field)
 }
 
@@ -44,10 +47,6 @@ const (
 )
 
 func InitZapLog(logLv, logDir, logName string, minute, maxAge int) LogI {
-	stdoutLog := &ZapLog{}
-	if stdoutLog == nil {
-		return nil
-	}
 	config := &LogConfig{
 		LogDir:     logDir,
 		LogName:    logName,
@@ -56,7 +55,11 @@ func InitZapLog(logLv, logDir, logName string, minute, maxAge int) LogI {
 		Minute:     minute,
 		MaxAge:     maxAge,
 	}
+	return InitZapLogConfig(config)
+}
 
+func InitZapLogConfig(config *LogConfig) LogI {
+	stdoutLog := &ZapLog{}
 	if stdoutLog.Init(config) {
 		return stdoutLog
 	}
@@ -70,6 +73,9 @@ type ZapLog struct {
 	zapLogLevel *zap.AtomicLevel
 	encoder     zapcore.Encoder
 	writer      io.Writer
+
+	debugLog  *zap.Logger // 不受日志等级限制, 给 debugUids 和 AuditWF 用
+	debugUids *debugUids
 }
 
 func (zl *ZapLog) SetLogLevel(strLevel string) {
@@ -84,6 +90,10 @@ func (zl *ZapLog) SetLogId(logId int64) {
 	zl.logId = logId
 }
 
+func (zl *ZapLog) GetLogId() int64 {
+	return zl.logId
+}
+
 func (zl *ZapLog) SetUid(uid uint64) {
 	zl.uid = uid
 }
@@ -98,9 +108,46 @@ func (zl *ZapLog) Clone() LogI {
 	out.zapLogLevel = zl.zapLogLevel
 	out.encoder = zl.encoder
 	out.writer = zl.writer
+	out.debugLog = zl.debugLog
+	out.debugUids = zl.debugUids
 	return out
 }
 
+// Close flushes zap and closes the log file, the writer is shared by every Clone.
+func (zl *ZapLog) Close() error {
+	if zl.log == nil {
+		return nil
+	}
+	_ = zl.log.Sync()
+	if c, ok := zl.writer.(io.Closer); ok && zl.writer != io.Writer(os.Stdout) {
+		return c.Close()
+	}
+	return nil
+}
+
+// Rotate forces the log file to rotate, it is a no-op when logging to stdout.
+func (zl *ZapLog) Rotate() error {
+	if rf, ok := zl.writer.(*rotatefile.RotateFile); ok {
+		return rf.Rotate()
+	}
+	return nil
+}
+
+// SetRotateMinute changes the rotation interval of the log file.
+func (zl *ZapLog) SetRotateMinute(minute int) {
+	if rf, ok := zl.writer.(*rotatefile.RotateFile); ok {
+		rf.SetMinute(minute)
+	}
+}
+
+// FileStats returns the counters of the log file, false when logging to stdout.
+func (zl *ZapLog) FileStats() (rotatefile.Stats, bool) {
+	if rf, ok := zl.writer.(*rotatefile.RotateFile); ok {
+		return rf.Stats(), true
+	}
+	return rotatefile.Stats{}, false
+}
+
 func encodeTimeLayout(t time.Time, layout string, enc zapcore.PrimitiveArrayEncoder) {
 	type appendTimeEncoder interface {
 		AppendTimeLayout(time.Time, string)
@@ -165,6 +212,10 @@ func (zl *ZapLog) initZapLogger(config *LogConfig, disableStacktrace bool) bool
 	//zl.level = zapLogLevel.Level()
 	zl.log = zl.log.Named(config.AppPrefix)
 
+	debugCore := zapcore.NewCore(zl.encoder, zapcore.AddSync(logOut), zapcore.DebugLevel)
+	zl.debugLog = zap.New(debugCore, opts...).Named(config.AppPrefix)
+	zl.debugUids = newDebugUids()
+
 	return true
 }
 
@@ -179,8 +230,9 @@ func (zl *ZapLog) DebugWF(msg string, fields ...zapcore.Field) {
 
 	zl.AddLog(&fields)
 
+	log := zl.debugLogger()
 	func() {
-		zl.log.Debug(msg, fields...)
+		log.Debug(msg, fields...)
 	}()
 }
 
@@ -219,3 +271,16 @@ func (zl *ZapLog) ErrorWF(msg string, fields ...zapcore.Field) {
 		zl.log.Error(msg, fields...)
 	}()
 }
+
+// AuditWF is written whatever the log level is, for records that must not be filtered out.
+func (zl *ZapLog) AuditWF(msg string, fields ...zapcore.Field) {
+	if zl.debugLog == nil {
+		return
+	}
+
+	zl.AddLog(&fields)
+
+	func() {
+		zl.debugLog.Info(msg, fields...)
+	}()
+}
@@ -19,3 +19,12 @@ func CaptureException() {
 	}
 	return
 }
+
+func InStrings(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,379 @@
+/*
+@Author: agent
+@Date: 2026/10/16 21:05
+@Description: rfc 6455 framing, control frames and close handshake
+*/
+
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/buffer"
+)
+
+type Opcode byte
+
+const (
+	OpContinuation Opcode = 0x0
+	OpText         Opcode = 0x1
+	OpBinary       Opcode = 0x2
+	OpClose        Opcode = 0x8
+	OpPing         Opcode = 0x9
+	OpPong         Opcode = 0xa
+)
+
+func (op Opcode) control() bool {
+	return op&0x8 != 0
+}
+
+// close codes of RFC 6455 section 7.4.1
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseTooBig          = 1009
+	CloseInternalError   = 1011
+)
+
+const (
+	DefaultMaxMessageSize = 64 << 10
+	DefaultWriteTimeout   = 10 * time.Second
+
+	maxControlPayload = 125
+	maxFrameHeader    = 14
+)
+
+var ErrClosed = errors.New("websocket: connection closed")
+
+// CloseError ends ReadMessage, it carries the code the peer sent or the one we closed with.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return "websocket: close " + strconv.Itoa(e.Code) + " " + e.Reason
+}
+
+// CloseCode returns the code of a CloseError, CloseNoStatus for any other error.
+func CloseCode(err error) int {
+	var ce *CloseError
+	if errors.As(err, &ce) {
+		return ce.Code
+	}
+	return CloseNoStatus
+}
+
+type Options struct {
+	MaxMessageSize int           // larger messages close the connection with 1009, 0 uses DefaultMaxMessageSize
+	ReadTimeout    time.Duration // no frame at all for this long fails the read, 0 waits forever
+	WriteTimeout   time.Duration // 0 uses DefaultWriteTimeout
+}
+
+// Conn is the server side of a websocket. One goroutine may read while others write,
+// writes are serialized.
+type Conn struct {
+	conn net.Conn
+	br   *bufio.Reader
+	opts Options
+
+	// reader side
+	rbuf    buffer.IoBuffer // frames as they come off the wire
+	msg     buffer.IoBuffer // fragments of the message being read, nil between messages
+	msgOp   Opcode
+	readErr error
+	onPong  func(data []byte)
+
+	wmu       sync.Mutex
+	closeSent bool
+	closed    atomic.Bool
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, opts Options) *Conn {
+	if opts.MaxMessageSize <= 0 {
+		opts.MaxMessageSize = DefaultMaxMessageSize
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = DefaultWriteTimeout
+	}
+	return &Conn{conn: conn, br: br, opts: opts, rbuf: buffer.GetIoBuffer(buffer.MinRead)}
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// OnPong is called with the payload of every pong, set it before reading.
+func (c *Conn) OnPong(fn func(data []byte)) {
+	c.onPong = fn
+}
+
+// ReadMessage returns the next text or binary message, pings are answered and pongs handed to
+// OnPong on the way. The caller owns the buffer and gives it back with buffer.PutIoBuffer.
+// After an error every call returns it again, a *CloseError when the connection was closed.
+func (c *Conn) ReadMessage() (Opcode, buffer.IoBuffer, error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+	op, msg, err := c.readMessage()
+	if err != nil {
+		c.readErr = err
+		c.releaseRead()
+		_ = c.conn.Close()
+	}
+	return op, msg, err
+}
+
+func (c *Conn) readMessage() (Opcode, buffer.IoBuffer, error) {
+	for {
+		head, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		payload := c.rbuf.Bytes()[head.size : head.size+head.length]
+
+		if head.op.control() {
+			data := make([]byte, len(payload))
+			maskBytes(data, payload, head.mask)
+			c.rbuf.Drain(head.size + head.length)
+
+			switch head.op {
+			case OpPing:
+				if err = c.writeFrame(OpPong, data); err != nil {
+					return 0, nil, err
+				}
+			case OpPong:
+				if c.onPong != nil {
+					c.onPong(data)
+				}
+			case OpClose:
+				return 0, nil, c.closeReceived(data)
+			}
+			continue
+		}
+
+		if head.op == OpContinuation && c.msg == nil {
+			return 0, nil, c.fail(CloseProtocolError, "continuation without a message")
+		}
+		if head.op != OpContinuation && c.msg != nil {
+			return 0, nil, c.fail(CloseProtocolError, "new message inside a fragmented one")
+		}
+		if c.msg == nil {
+			c.msg, c.msgOp = buffer.GetIoBuffer(head.length), head.op
+		}
+		if c.msg.Len()+head.length > c.opts.MaxMessageSize {
+			return 0, nil, c.fail(CloseTooBig, "message too big")
+		}
+
+		start := c.msg.Len()
+		_, _ = c.msg.Write(payload)
+		data := c.msg.Bytes()[start:]
+		maskBytes(data, data, head.mask)
+		c.rbuf.Drain(head.size + head.length)
+		if !head.fin {
+			continue
+		}
+
+		msg, op := c.msg, c.msgOp
+		c.msg = nil
+		if op == OpText && !utf8.Valid(msg.Bytes()) {
+			_ = buffer.PutIoBuffer(msg)
+			return 0, nil, c.fail(CloseInvalidPayload, "text is not utf-8")
+		}
+		return op, msg, nil
+	}
+}
+
+type frameHead struct {
+	fin    bool
+	op     Opcode
+	mask   [4]byte
+	size   int // header bytes
+	length int // payload bytes
+}
+
+// readFrame waits until rbuf holds a whole frame and returns its header, the payload follows it.
+func (c *Conn) readFrame() (frameHead, error) {
+	var head frameHead
+	for {
+		raw := c.rbuf.Bytes()
+		if len(raw) >= 2 {
+			head.fin = raw[0]&0x80 != 0
+			head.op = Opcode(raw[0] & 0x0f)
+			if raw[0]&0x70 != 0 {
+				return head, c.fail(CloseProtocolError, "reserved bits set")
+			}
+			if raw[1]&0x80 == 0 {
+				return head, c.fail(CloseProtocolError, "client frame not masked")
+			}
+			switch head.op {
+			case OpContinuation, OpText, OpBinary, OpClose, OpPing, OpPong:
+			default:
+				return head, c.fail(CloseProtocolError, "unknown opcode")
+			}
+
+			length, size := uint64(raw[1]&0x7f), 2
+			switch length {
+			case 126:
+				size += 2
+			case 127:
+				size += 8
+			}
+			size += 4
+			if len(raw) >= size {
+				switch length {
+				case 126:
+					length = uint64(binary.BigEndian.Uint16(raw[2:]))
+				case 127:
+					length = binary.BigEndian.Uint64(raw[2:])
+				}
+				if head.op.control() && (!head.fin || length > maxControlPayload) {
+					return head, c.fail(CloseProtocolError, "bad control frame")
+				}
+				if length > uint64(c.opts.MaxMessageSize) {
+					return head, c.fail(CloseTooBig, "message too big")
+				}
+				copy(head.mask[:], raw[size-4:size])
+				head.size, head.length = size, int(length)
+				if len(raw) >= head.size+head.length {
+					return head, nil
+				}
+			}
+		}
+
+		if c.opts.ReadTimeout > 0 {
+			_ = c.conn.SetReadDeadline(time.Now().Add(c.opts.ReadTimeout))
+		}
+		if n, err := c.rbuf.ReadOnce(c.br); n == 0 && err != nil {
+			if c.closed.Load() {
+				return head, ErrClosed
+			}
+			return head, err
+		}
+	}
+}
+
+func maskBytes(dst, src []byte, key [4]byte) {
+	for i := range src {
+		dst[i] = src[i] ^ key[i&3]
+	}
+}
+
+// closeReceived answers the close of the peer with the same code.
+func (c *Conn) closeReceived(data []byte) error {
+	ce := &CloseError{Code: CloseNoStatus}
+	if len(data) >= 2 {
+		ce.Code, ce.Reason = int(binary.BigEndian.Uint16(data)), string(data[2:])
+		if !utf8.ValidString(ce.Reason) {
+			return c.fail(CloseProtocolError, "close reason is not utf-8")
+		}
+	} else if len(data) == 1 {
+		return c.fail(CloseProtocolError, "bad close payload")
+	}
+
+	code := ce.Code
+	if code == CloseNoStatus {
+		code = CloseNormal
+	}
+	_ = c.writeFrame(OpClose, closePayload(code, ""))
+	return ce
+}
+
+// fail closes the connection for a protocol violation of the peer.
+func (c *Conn) fail(code int, reason string) error {
+	_ = c.Close(code, reason)
+	return &CloseError{Code: code, Reason: reason}
+}
+
+func (c *Conn) releaseRead() {
+	if c.msg != nil {
+		_ = buffer.PutIoBuffer(c.msg)
+		c.msg = nil
+	}
+	if c.rbuf != nil {
+		_ = buffer.PutIoBuffer(c.rbuf)
+		c.rbuf = nil
+	}
+}
+
+func closePayload(code int, reason string) []byte {
+	if len(reason) > maxControlPayload-2 {
+		reason = reason[:maxControlPayload-2]
+	}
+	data := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(data, uint16(code))
+	return append(data, reason...)
+}
+
+// writeFrame sends one unmasked frame, the header and payload go out in a single write.
+func (c *Conn) writeFrame(op Opcode, data []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return ErrClosed
+	}
+
+	buf := buffer.GetIoBuffer(maxFrameHeader + len(data))
+	defer buffer.PutIoBuffer(buf)
+
+	var head [maxFrameHeader]byte
+	head[0] = 0x80 | byte(op)
+	n := 2
+	switch {
+	case len(data) < 126:
+		head[1] = byte(len(data))
+	case len(data) <= 0xffff:
+		head[1] = 126
+		binary.BigEndian.PutUint16(head[2:], uint16(len(data)))
+		n += 2
+	default:
+		head[1] = 127
+		binary.BigEndian.PutUint64(head[2:], uint64(len(data)))
+		n += 8
+	}
+	_, _ = buf.Write(head[:n])
+	_, _ = buf.Write(data)
+
+	if op == OpClose {
+		c.closeSent = true
+	}
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.opts.WriteTimeout))
+	_, err := buf.WriteTo(c.conn)
+	return err
+}
+
+// WriteMessage sends data as a single text or binary frame.
+func (c *Conn) WriteMessage(op Opcode, data []byte) error {
+	if op != OpText && op != OpBinary {
+		return errors.New("websocket: WriteMessage needs text or binary")
+	}
+	return c.writeFrame(op, data)
+}
+
+// Ping sends a ping, the answer goes to OnPong.
+func (c *Conn) Ping(data []byte) error {
+	return c.writeFrame(OpPing, data)
+}
+
+// Close sends a close frame with code and reason and closes the connection, a pending
+// ReadMessage returns ErrClosed. Closing twice is a no-op.
+func (c *Conn) Close(code int, reason string) error {
+	if !c.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	_ = c.writeFrame(OpClose, closePayload(code, reason))
+	return c.conn.Close()
+}
@@ -0,0 +1,102 @@
+/*
+@Author: agent
+@Date: 2026/10/16 21:05
+@Description: rfc 6455 opening handshake
+*/
+
+package websocket
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// acceptGuid is appended to Sec-WebSocket-Key before hashing, RFC 6455 section 1.3.
+const acceptGuid = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// HandshakeError is a request that cannot be upgraded, Status is the http answer.
+type HandshakeError struct {
+	Status int
+	Msg    string
+}
+
+func (e *HandshakeError) Error() string {
+	return "websocket: " + e.Msg
+}
+
+// headerHas reports whether the comma separated header contains token, case-insensitively.
+func headerHas(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsUpgrade reports whether r asks for a websocket.
+func IsUpgrade(r *http.Request) bool {
+	return headerHas(r.Header, "Connection", "upgrade") && headerHas(r.Header, "Upgrade", "websocket")
+}
+
+// AcceptKey returns the Sec-WebSocket-Accept for key.
+func AcceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGuid))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func checkHandshake(r *http.Request) (string, error) {
+	if r.Method != http.MethodGet {
+		return "", &HandshakeError{Status: http.StatusMethodNotAllowed, Msg: "upgrade needs GET"}
+	}
+	if !IsUpgrade(r) {
+		return "", &HandshakeError{Status: http.StatusBadRequest, Msg: "not a websocket upgrade"}
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return "", &HandshakeError{Status: http.StatusUpgradeRequired, Msg: "unsupported version"}
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if raw, err := base64.StdEncoding.DecodeString(key); err != nil || len(raw) != 16 {
+		return "", &HandshakeError{Status: http.StatusBadRequest, Msg: "bad Sec-WebSocket-Key"}
+	}
+	return key, nil
+}
+
+// Upgrade checks the handshake, hijacks the connection and answers 101. On a HandshakeError
+// nothing was written, the caller answers with e.Status; w may wrap the server writer as long
+// as it has Unwrap.
+func Upgrade(w http.ResponseWriter, r *http.Request, opts Options) (*Conn, error) {
+	key, err := checkHandshake(r)
+	if err != nil {
+		if err.(*HandshakeError).Status == http.StatusUpgradeRequired {
+			w.Header().Set("Sec-WebSocket-Version", "13")
+		}
+		return nil, err
+	}
+
+	netConn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, err
+	}
+	// the server deadlines were for the http request, the connection now manages its own
+	_ = netConn.SetDeadline(time.Time{})
+
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + AcceptKey(key) + "\r\n\r\n"
+	if _, err = brw.WriteString(resp); err == nil {
+		err = brw.Flush()
+	}
+	if err != nil {
+		_ = netConn.Close()
+		return nil, err
+	}
+	// a client may send its first frame right behind the handshake, it is in the buffered reader
+	return newConn(netConn, brw.Reader, opts), nil
+}
@@ -0,0 +1,208 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/buffer"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// the example of RFC 6455 section 1.3
+	if got := AcceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("unexpected accept %s", got)
+	}
+}
+
+// echoServer echoes messages until the connection ends and reports how it ended.
+func echoServer(t *testing.T, opts Options) (*httptest.Server, chan error) {
+	done := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r, opts)
+		if err != nil {
+			http.Error(w, err.Error(), err.(*HandshakeError).Status)
+			return
+		}
+		for {
+			op, msg, err := c.ReadMessage()
+			if err != nil {
+				done <- err
+				return
+			}
+			_ = c.WriteMessage(op, msg.Bytes())
+			_ = buffer.PutIoBuffer(msg)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, done
+}
+
+type client struct {
+	net.Conn
+	br *bufio.Reader
+}
+
+func dial(t *testing.T, srv *httptest.Server) *client {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	_, _ = io.WriteString(conn, "GET / HTTP/1.1\r\nHost: test\r\nConnection: keep-alive, Upgrade\r\nUpgrade: websocket\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected handshake %d %v", resp.StatusCode, resp.Header)
+	}
+	return &client{Conn: conn, br: br}
+}
+
+func (c *client) send(fin bool, op Opcode, payload []byte, masked bool) {
+	var frame bytes.Buffer
+	b0 := byte(op)
+	if fin {
+		b0 |= 0x80
+	}
+	frame.WriteByte(b0)
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+	switch {
+	case len(payload) < 126:
+		frame.WriteByte(maskBit | byte(len(payload)))
+	default:
+		frame.WriteByte(maskBit | 126)
+		_ = binary.Write(&frame, binary.BigEndian, uint16(len(payload)))
+	}
+	data := append([]byte{}, payload...)
+	if masked {
+		key := [4]byte{1, 2, 3, 4}
+		frame.Write(key[:])
+		maskBytes(data, data, key)
+	}
+	frame.Write(data)
+	_, _ = c.Write(frame.Bytes())
+}
+
+func (c *client) recv(t *testing.T) (Opcode, []byte) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	n := int(head[1] & 0x7f)
+	if n == 126 {
+		var ext [2]byte
+		_, _ = io.ReadFull(c.br, ext[:])
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(c.br, data); err != nil {
+		t.Fatal(err)
+	}
+	return Opcode(head[0] & 0x0f), data
+}
+
+func TestEcho(t *testing.T) {
+	srv, done := echoServer(t, Options{})
+	c := dial(t, srv)
+
+	c.send(true, OpText, []byte("hello"), true)
+	if op, data := c.recv(t); op != OpText || string(data) != "hello" {
+		t.Errorf("unexpected echo %v %q", op, data)
+	}
+
+	// a ping between fragments is answered at once
+	big := bytes.Repeat([]byte("x"), 300)
+	c.send(false, OpBinary, big[:100], true)
+	c.send(true, OpPing, []byte("p"), true)
+	c.send(true, OpContinuation, big[100:], true)
+	if op, data := c.recv(t); op != OpPong || string(data) != "p" {
+		t.Errorf("expect pong, got %v %q", op, data)
+	}
+	if op, data := c.recv(t); op != OpBinary || !bytes.Equal(data, big) {
+		t.Errorf("unexpected fragmented echo %v %d", op, len(data))
+	}
+
+	c.send(true, OpClose, closePayload(CloseGoingAway, "bye"), true)
+	if op, data := c.recv(t); op != OpClose || binary.BigEndian.Uint16(data) != CloseGoingAway {
+		t.Errorf("expect close echo, got %v %v", op, data)
+	}
+	if err := <-done; CloseCode(err) != CloseGoingAway {
+		t.Errorf("expect going away, got %v", err)
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		send func(c *client)
+		code int
+	}{
+		{"unmasked", func(c *client) { c.send(true, OpText, []byte("a"), false) }, CloseProtocolError},
+		{"continuation", func(c *client) { c.send(true, OpContinuation, []byte("a"), true) }, CloseProtocolError},
+		{"utf8", func(c *client) { c.send(true, OpText, []byte{0xff, 0xfe}, true) }, CloseInvalidPayload},
+		{"too big", func(c *client) { c.send(true, OpBinary, make([]byte, 200), true) }, CloseTooBig},
+	} {
+		srv, done := echoServer(t, Options{MaxMessageSize: 128})
+		cl := dial(t, srv)
+		c.send(cl)
+		if op, data := cl.recv(t); op != OpClose || int(binary.BigEndian.Uint16(data)) != c.code {
+			t.Errorf("%s: expect close %d, got %v %v", c.name, c.code, op, data)
+		}
+		if err := <-done; CloseCode(err) != c.code {
+			t.Errorf("%s: expect %d, got %v", c.name, c.code, err)
+		}
+	}
+}
+
+func TestHandshakeRejected(t *testing.T) {
+	srv, _ := echoServer(t, Options{})
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expect 400 for plain get, got %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "8")
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired || resp.Header.Get("Sec-WebSocket-Version") != "13" {
+		t.Errorf("expect 426, got %d %v", resp.StatusCode, resp.Header)
+	}
+}
+
+func TestReadTimeout(t *testing.T) {
+	srv, done := echoServer(t, Options{ReadTimeout: 50 * time.Millisecond})
+	dial(t, srv)
+	select {
+	case err := <-done:
+		if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+			t.Errorf("expect timeout, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("read did not time out")
+	}
+}
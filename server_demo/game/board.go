@@ -0,0 +1,107 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:58
+@Description: tic-tac-toe rules, the server decides every move instead of the tmp_game Board
+*/
+
+package game
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+var (
+	ErrBadCell     = errors.New("game: cell out of range")
+	ErrCellTaken   = errors.New("game: cell already taken")
+	ErrNotYourTurn = errors.New("game: not your turn")
+	ErrNotPlayer   = errors.New("game: not a player of the match")
+	ErrNotStarted  = errors.New("game: waiting for the second player")
+	ErrGameOver    = errors.New("game: game is over")
+)
+
+// Mark is what a square holds, the zero value is an empty square.
+type Mark byte
+
+const (
+	Empty Mark = 0
+	X     Mark = 'X'
+	O     Mark = 'O'
+)
+
+func (m Mark) String() string {
+	if m == Empty {
+		return ""
+	}
+	return string(m)
+}
+
+// Other returns the mark of the opponent.
+func (m Mark) Other() Mark {
+	if m == X {
+		return O
+	}
+	return X
+}
+
+// MarshalJSON writes "X", "O" or null like the squares array of the client.
+func (m Mark) MarshalJSON() ([]byte, error) {
+	if m == Empty {
+		return []byte("null"), nil
+	}
+	return json.Marshal(string(m))
+}
+
+func (m *Mark) UnmarshalJSON(data []byte) error {
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	switch {
+	case s == nil || *s == "":
+		*m = Empty
+	case *s == "X" || *s == "O":
+		*m = Mark((*s)[0])
+	default:
+		return errors.New("game: bad mark " + *s)
+	}
+	return nil
+}
+
+const Cells = 9
+
+// Board is the 3x3 grid, cell i is row i/3 column i%3.
+type Board [Cells]Mark
+
+// lines are the same as CheckWinner in react_demo/tmp_game.
+var lines = [8][3]int{
+	{0, 1, 2},
+	{3, 4, 5},
+	{6, 7, 8},
+	{0, 3, 6},
+	{1, 4, 7},
+	{2, 5, 8},
+	{0, 4, 8},
+	{2, 4, 6},
+}
+
+// Winner returns the mark owning a full line and the line, Empty when nobody won.
+func (b *Board) Winner() (Mark, []int) {
+	for _, l := range lines {
+		a := b[l[0]]
+		if a != Empty && a == b[l[1]] && a == b[l[2]] {
+			return a, l[:]
+		}
+	}
+	return Empty, nil
+}
+
+// Full reports whether no square is empty.
+func (b *Board) Full() bool {
+	for _, m := range b {
+		if m == Empty {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package game
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMatchRules(t *testing.T) {
+	m := NewMatch(1, 10)
+	if err := m.Move(10, 4); !errors.Is(err, ErrNotStarted) {
+		t.Fatalf("expect not started, got %v", err)
+	}
+	if mark, err := m.Join(20); err != nil || mark != O {
+		t.Fatalf("join: %v %v", mark, err)
+	}
+	if _, err := m.Join(30); !errors.Is(err, ErrNotPlayer) {
+		t.Errorf("expect full match, got %v", err)
+	}
+
+	for _, c := range []struct {
+		uid  uint64
+		cell int
+		err  error
+	}{
+		{20, 0, ErrNotYourTurn}, // X moves first
+		{30, 0, ErrNotPlayer},
+		{10, 9, ErrBadCell},
+		{10, 4, nil},
+		{20, 4, ErrCellTaken},
+		{20, 0, nil},
+		{10, 2, nil},
+		{20, 6, nil},
+		{10, 3, nil},
+		{20, 5, nil},
+		{10, 8, nil},
+		{20, 1, nil},
+		{10, 7, nil}, // X: 4 2 3 8 7, O: 0 6 5 1
+		{20, 7, ErrGameOver},
+	} {
+		if err := m.Move(c.uid, c.cell); !errors.Is(err, c.err) {
+			t.Fatalf("move %d by %d: expect %v, got %v", c.cell, c.uid, c.err, err)
+		}
+	}
+
+	s := m.State()
+	if s.Winner != "" || !s.Draw || s.Next != "" || len(s.Moves) != 9 {
+		t.Errorf("expect draw, got %+v", s)
+	}
+}
+
+func TestMatchWinner(t *testing.T) {
+	m := NewMatch(1, 10)
+	m.Join(20)
+	for i, cell := range []int{0, 3, 1, 4, 2} {
+		uid := uint64(10)
+		if i%2 == 1 {
+			uid = 20
+		}
+		if err := m.Move(uid, cell); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := m.State()
+	if s.Winner != "X" || s.Draw || len(s.Line) != 3 || s.Line[2] != 2 {
+		t.Errorf("expect X on the top row, got %+v", s)
+	}
+	if !m.Over() {
+		t.Error("expect over")
+	}
+}
@@ -0,0 +1,193 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:58
+@Description: tic-tac-toe rooms over http, players are the uids of their sessions
+*/
+
+package game
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/auth"
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"github.com/Xbzzy/client_demo/server_demo/process"
+	"go.uber.org/zap"
+)
+
+// apiError turns rule errors into responses, anything else stays a 500.
+func apiError(err error) error {
+	switch {
+	case errors.Is(err, ErrBadCell):
+		return process.BadRequest(err.Error())
+	case errors.Is(err, ErrNotPlayer):
+		return process.Forbidden(err.Error())
+	case errors.Is(err, ErrCellTaken), errors.Is(err, ErrNotYourTurn), errors.Is(err, ErrNotStarted),
+		errors.Is(err, ErrGameOver), errors.Is(err, ErrRoomFull), errors.Is(err, ErrRoomClosed),
+		errors.Is(err, ErrTooManyRooms):
+		return process.Conflict(err.Error())
+	case errors.Is(err, ErrRoomsStopped):
+		return process.NewError(http.StatusServiceUnavailable, err.Error())
+	}
+	return err
+}
+
+func (rs *Rooms) room(params process.Params) (*Room, error) {
+	id, err := strconv.ParseInt(params.Get("id"), 10, 64)
+	if err != nil {
+		return nil, process.BadRequest("bad room id")
+	}
+	r := rs.Get(id)
+	if r == nil {
+		return nil, process.NotFound("room not found")
+	}
+	return r, nil
+}
+
+type joinReq struct {
+	Spectate bool `json:"spectate"`
+}
+
+type moveReq struct {
+	Cell *int `json:"cell"`
+}
+
+func (r *moveReq) Validate() error {
+	if r.Cell == nil {
+		return errors.New("cell is required")
+	}
+	return nil
+}
+
+// MsgPlaceMark is the message id of PlaceMark on every transport.
+const MsgPlaceMark uint32 = 100
+
+// PlaceMark is the move of a player sent through the dispatcher, by http, websocket or tcp.
+type PlaceMark struct {
+	RoomId int64 `json:"roomId,string"`
+	Cell   *int  `json:"cell"`
+}
+
+func (m *PlaceMark) Validate() error {
+	if m.RoomId == 0 {
+		return errors.New("roomId is required")
+	}
+	if m.Cell == nil {
+		return errors.New("cell is required")
+	}
+	return nil
+}
+
+func (rs *Rooms) create(ctx context.Context, logger simplelog.LogI, _ process.Params, _ *struct{}) (*RoomInfo, error) {
+	r, err := rs.Create(logger, process.UidFrom(ctx))
+	if err != nil {
+		return nil, apiError(err)
+	}
+	return r.Info(), nil
+}
+
+// lobby lists the rooms waiting for a second player.
+func (rs *Rooms) lobby(_ context.Context, _ simplelog.LogI, _ process.Params, _ *struct{}) ([]*RoomInfo, error) {
+	rooms := rs.List(Waiting)
+	list := make([]*RoomInfo, 0, len(rooms))
+	for _, r := range rooms {
+		list = append(list, r.Info())
+	}
+	return list, nil
+}
+
+func (rs *Rooms) info(_ context.Context, _ simplelog.LogI, params process.Params, _ *struct{}) (*RoomInfo, error) {
+	r, err := rs.room(params)
+	if err != nil {
+		return nil, err
+	}
+	return r.Info(), nil
+}
+
+func (rs *Rooms) join(ctx context.Context, logger simplelog.LogI, params process.Params, req *joinReq) (*RoomInfo, error) {
+	r, err := rs.room(params)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = rs.Join(logger, r, process.UidFrom(ctx), req.Spectate); err != nil {
+		return nil, apiError(err)
+	}
+	return r.Info(), nil
+}
+
+func (rs *Rooms) leave(ctx context.Context, logger simplelog.LogI, params process.Params, _ *struct{}) (*RoomInfo, error) {
+	r, err := rs.room(params)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.Leave(logger, process.UidFrom(ctx)); err != nil {
+		return nil, apiError(err)
+	}
+	rs.notify(r)
+	return r.Info(), nil
+}
+
+func (rs *Rooms) move(ctx context.Context, logger simplelog.LogI, params process.Params, req *moveReq) (*RoomInfo, error) {
+	r, err := rs.room(params)
+	if err != nil {
+		return nil, err
+	}
+	return rs.place(ctx, logger, r, *req.Cell)
+}
+
+func (rs *Rooms) placeMark(ctx context.Context, logger simplelog.LogI, req *PlaceMark) (*RoomInfo, error) {
+	r := rs.Get(req.RoomId)
+	if r == nil {
+		return nil, process.NotFound("room not found")
+	}
+	return rs.place(ctx, logger, r, *req.Cell)
+}
+
+// place is the move behind both the room route and PlaceMark.
+func (rs *Rooms) place(ctx context.Context, logger simplelog.LogI, r *Room, cell int) (*RoomInfo, error) {
+	if err := r.Move(logger, process.UidFrom(ctx), cell); err != nil {
+		logger.DebugWF("game move refused", zap.Int64("roomId", r.Id), zap.Int("cell", cell), zap.Error(err))
+		return nil, apiError(err)
+	}
+	rs.notify(r)
+
+	info := r.Info()
+	if info.Status == Finished {
+		logger.InfoWF("game match over", zap.Int64("roomId", r.Id), zap.String("winner", info.Match.Winner),
+			zap.Ints("moves", info.Match.Moves))
+	}
+	return info, nil
+}
+
+func (rs *Rooms) close(_ context.Context, logger simplelog.LogI, params process.Params, _ *struct{}) (*RoomInfo, error) {
+	r, err := rs.room(params)
+	if err != nil {
+		return nil, err
+	}
+	r.Close(logger, "closed by operator")
+	rs.notify(r)
+	return r.Info(), nil
+}
+
+// Register mounts the room api on the default router, every route needs a session since
+// players are told apart by uid.
+func (rs *Rooms) Register(l simplelog.LogI) {
+	login := process.RequireLogin()
+
+	process.SafeHttpHandle(l, http.MethodPost, "/game/rooms", process.JsonHandler(rs.create), login)
+	process.SafeHttpHandle(l, http.MethodGet, "/game/rooms", process.JsonHandler(rs.lobby), login)
+	process.SafeHttpHandle(l, http.MethodGet, "/game/rooms/{id}", process.JsonHandler(rs.info), login)
+	process.SafeHttpHandle(l, http.MethodPost, "/game/rooms/{id}/join", process.JsonHandler(rs.join), login)
+	process.SafeHttpHandle(l, http.MethodPost, "/game/rooms/{id}/leave", process.JsonHandler(rs.leave), login)
+	process.SafeHttpHandle(l, http.MethodPost, "/game/rooms/{id}/move", process.JsonHandler(rs.move), login)
+	process.SafeHttpHandle(l, http.MethodDelete, "/game/rooms/{id}", process.JsonHandler(rs.close),
+		process.RequireRoles(auth.RoleOperator))
+}
+
+// RegisterMsgs adds the game messages to d, they work over every transport d is served on.
+func (rs *Rooms) RegisterMsgs(d *process.Dispatcher) {
+	process.HandleMsg(d, MsgPlaceMark, "PlaceMark", rs.placeMark)
+}
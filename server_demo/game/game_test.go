@@ -0,0 +1,125 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/auth"
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"github.com/Xbzzy/client_demo/server_demo/process"
+)
+
+func request(method, path, token, body string) (*httptest.ResponseRecorder, *RoomInfo) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	process.DefaultRouter.ServeHTTP(w, req)
+	var info RoomInfo
+	_ = json.Unmarshal(w.Body.Bytes(), &process.Envelope{Data: &info})
+	return w, &info
+}
+
+func TestRoomsHttp(t *testing.T) {
+	signer, err := auth.NewSigner([]byte("0123456789abcdef"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &simplelog.ZapLog{}
+	process.DefaultRouter.Use(process.NewAuth(signer, nil).Middleware)
+	NewRooms(l, 0, 0, 0).Register(l)
+	// the tmp_game build is mounted on /game too, the room routes are more specific
+	process.SafeHttpHandle(l, http.MethodGet, "/game/{path...}", func(simplelog.LogI, process.Params, http.ResponseWriter, *http.Request) {
+		t.Error("static site got a room route")
+	})
+
+	x, _, _ := signer.Issue(10, "test")
+	o, _, _ := signer.Issue(20, "test")
+
+	if w, _ := request(http.MethodPost, "/game/rooms", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expect 401 without login, got %d", w.Code)
+	}
+	w, s := request(http.MethodPost, "/game/rooms", x, "")
+	if w.Code != http.StatusOK || s.Status != Waiting || s.Match.X != 10 || s.Match.Next != "X" {
+		t.Fatalf("create: %d %s", w.Code, w.Body.String())
+	}
+	path := "/game/rooms/" + strconv.FormatInt(s.Id, 10)
+
+	if w, _ = request(http.MethodPost, path+"/move", x, `{"cell":4}`); w.Code != http.StatusConflict {
+		t.Errorf("expect conflict before join, got %d", w.Code)
+	}
+	if w, s = request(http.MethodPost, path+"/join", o, ""); w.Code != http.StatusOK || s.Status != Playing || s.Match.O != 20 {
+		t.Fatalf("join: %d %s", w.Code, w.Body.String())
+	}
+	if w, _ = request(http.MethodPost, path+"/move", x, `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expect 400 without cell, got %d", w.Code)
+	}
+	if w, _ = request(http.MethodPost, path+"/move", o, `{"cell":4}`); w.Code != http.StatusConflict {
+		t.Errorf("expect not your turn, got %d", w.Code)
+	}
+	if w, s = request(http.MethodPost, path+"/move", x, `{"cell":4}`); w.Code != http.StatusOK || s.Match.Squares[4] != X || s.Match.Next != "O" {
+		t.Fatalf("move: %d %s", w.Code, w.Body.String())
+	}
+	if w, _ = request(http.MethodGet, path, o, ""); !strings.Contains(w.Body.String(), `"squares":[null,null,null,null,"X"`) {
+		t.Errorf("unexpected state %s", w.Body.String())
+	}
+	if w, _ = request(http.MethodGet, "/game/rooms/1", o, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expect 404, got %d", w.Code)
+	}
+	if w, _ = request(http.MethodDelete, path, o, ""); w.Code != http.StatusForbidden {
+		t.Errorf("expect players not to close rooms, got %d", w.Code)
+	}
+	if w, s = request(http.MethodPost, path+"/leave", o, ""); s.Status != Finished || s.Match.Winner != "X" || !s.Match.Forfeit {
+		t.Errorf("expect forfeit, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+// TestPlaceMark plays one room through the dispatcher, by Dispatch like websocket and tcp do and by http.
+func TestPlaceMark(t *testing.T) {
+	signer, err := auth.NewSigner([]byte("0123456789abcdef"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &simplelog.ZapLog{}
+	rs := NewRooms(l, 0, 0, 0)
+	d := process.NewDispatcher()
+	rs.RegisterMsgs(d)
+	rt := process.NewRouter(l)
+	rt.Use(process.NewAuth(signer, nil).Middleware)
+	rt.POST("/msg/{id}", d.HttpHandler(), process.RequireLogin())
+
+	r := newTestRoom(t, rs, 10)
+	if _, err = r.Join(l, 20, false); err != nil {
+		t.Fatal(err)
+	}
+	room := strconv.FormatInt(r.Id, 10)
+	asX := process.WithSession(context.Background(), &auth.Claims{Uid: 10})
+
+	var s RoomInfo
+	if env := d.Dispatch(asX, l, MsgPlaceMark, []byte(`{"roomId":"`+room+`","cell":4}`)); env.Code != process.CodeOk {
+		t.Fatalf("dispatch: %+v", env)
+	}
+	if env := d.Dispatch(asX, l, MsgPlaceMark, []byte(`{"roomId":"`+room+`","cell":0}`)); env.Code != http.StatusConflict {
+		t.Errorf("expect not your turn, got %+v", env)
+	}
+	if env := d.Dispatch(asX, l, MsgPlaceMark, []byte(`{"roomId":"`+room+`"}`)); env.Code != http.StatusBadRequest {
+		t.Errorf("expect 400 without cell, got %+v", env)
+	}
+
+	o, _, _ := signer.Issue(20, "test")
+	req := httptest.NewRequest(http.MethodPost, "/msg/PlaceMark", strings.NewReader(`{"roomId":"`+room+`","cell":0}`))
+	req.Header.Set("Authorization", "Bearer "+o)
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, req)
+	_ = json.Unmarshal(w.Body.Bytes(), &process.Envelope{Data: &s})
+	if w.Code != http.StatusOK || s.Match.Squares[4] != X || s.Match.Squares[0] != O || s.Match.Next != "X" {
+		t.Errorf("http: %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,170 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:58
+@Description: one tic-tac-toe match with turn and win checks
+*/
+
+package game
+
+import (
+	"sync"
+	"time"
+)
+
+// Match is one game between two players, X always moves first.
+type Match struct {
+	Id int64
+
+	mu      sync.Mutex
+	board   Board
+	next    Mark
+	moves   []int // cells in play order, for replays
+	players [2]uint64
+	winner  Mark
+	line    []int
+	draw    bool
+	forfeit bool
+	updated time.Time
+}
+
+// NewMatch creates a match where uid plays X, the second player joins as O.
+func NewMatch(id int64, uid uint64) *Match {
+	return &Match{Id: id, next: X, players: [2]uint64{uid, 0}, updated: time.Now()}
+}
+
+// MarkOf returns the mark uid plays, Empty for spectators.
+func (m *Match) MarkOf(uid uint64) Mark {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.markOf(uid)
+}
+
+func (m *Match) markOf(uid uint64) Mark {
+	switch {
+	case uid == 0:
+		return Empty
+	case uid == m.players[0]:
+		return X
+	case uid == m.players[1]:
+		return O
+	}
+	return Empty
+}
+
+// Players returns the uids playing X and O, 0 for a free seat.
+func (m *Match) Players() (x, o uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.players[0], m.players[1]
+}
+
+// Join seats uid as O, joining again is a no-op.
+func (m *Match) Join(uid uint64) (Mark, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if mark := m.markOf(uid); mark != Empty {
+		return mark, nil
+	}
+	if m.players[1] != 0 {
+		return Empty, ErrNotPlayer
+	}
+	m.players[1] = uid
+	m.updated = time.Now()
+	return O, nil
+}
+
+func (m *Match) over() bool {
+	return m.winner != Empty || m.draw
+}
+
+// Move puts the mark of uid on cell after checking turn and square.
+func (m *Match) Move(uid uint64, cell int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mark := m.markOf(uid)
+	switch {
+	case mark == Empty:
+		return ErrNotPlayer
+	case m.over():
+		return ErrGameOver
+	case m.players[1] == 0:
+		return ErrNotStarted
+	case mark != m.next:
+		return ErrNotYourTurn
+	case cell < 0 || cell >= Cells:
+		return ErrBadCell
+	case m.board[cell] != Empty:
+		return ErrCellTaken
+	}
+
+	m.board[cell] = mark
+	m.moves = append(m.moves, cell)
+	m.next = mark.Other()
+	m.winner, m.line = m.board.Winner()
+	m.draw = m.winner == Empty && m.board.Full()
+	m.updated = time.Now()
+	return nil
+}
+
+// Forfeit ends the match with the opponent of uid as the winner.
+func (m *Match) Forfeit(uid uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mark := m.markOf(uid)
+	switch {
+	case mark == Empty:
+		return ErrNotPlayer
+	case m.over():
+		return ErrGameOver
+	}
+	m.winner, m.line, m.forfeit = mark.Other(), nil, true
+	m.updated = time.Now()
+	return nil
+}
+
+// State is a snapshot of the match sent to clients.
+type State struct {
+	Id      int64     `json:"id,string"`
+	Squares Board     `json:"squares"`
+	Next    string    `json:"next,omitempty"` // empty once over
+	Winner  string    `json:"winner,omitempty"`
+	Line    []int     `json:"line,omitempty"`
+	Draw    bool      `json:"draw"`
+	Forfeit bool      `json:"forfeit,omitempty"` // the loser left the room
+	Moves   []int     `json:"moves"`
+	X       uint64    `json:"x,string"`
+	O       uint64    `json:"o,string"` // 0 until the second player joins
+	Updated time.Time `json:"updated"`
+}
+
+func (m *Match) State() *State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := &State{
+		Id:      m.Id,
+		Squares: m.board,
+		Winner:  m.winner.String(),
+		Line:    m.line,
+		Draw:    m.draw,
+		Forfeit: m.forfeit,
+		Moves:   append([]int{}, m.moves...),
+		X:       m.players[0],
+		O:       m.players[1],
+		Updated: m.updated,
+	}
+	if !m.over() {
+		s.Next = m.next.String()
+	}
+	return s
+}
+
+// Over reports whether the match has a winner or is a draw.
+func (m *Match) Over() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.over()
+}
@@ -0,0 +1,248 @@
+/*
+@Author: agent
+@Date: 2026/10/16 21:01
+@Description: a room seats two players and any spectators around one match
+*/
+
+package game
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrRoomFull   = errors.New("game: room is full")
+	ErrRoomClosed = errors.New("game: room is closed")
+)
+
+// Status is where a room is in waiting -> playing -> finished -> closed, any status may close.
+type Status int32
+
+const (
+	Waiting  Status = iota // X is seated, O is not
+	Playing                // both seated, the match runs
+	Finished               // won, drawn or forfeited, players may still look at the board
+	Closed                 // gone, every call fails with ErrRoomClosed
+)
+
+var statusNames = [...]string{"waiting", "playing", "finished", "closed"}
+
+func (s Status) String() string {
+	if s < 0 || int(s) >= len(statusNames) {
+		return "unknown"
+	}
+	return statusNames[s]
+}
+
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Status) UnmarshalText(text []byte) error {
+	for i, name := range statusNames {
+		if name == string(text) {
+			*s = Status(i)
+			return nil
+		}
+	}
+	return errors.New("game: bad room status " + string(text))
+}
+
+// Room is created by the X player, the first player to join takes O and starts the match.
+type Room struct {
+	Id int64
+
+	mu            sync.Mutex
+	status        Status
+	match         *Match
+	left          [2]bool // players that left a finished room
+	spectators    map[uint64]struct{}
+	maxSpectators int
+	created       time.Time
+	active        time.Time
+}
+
+func newRoom(id int64, uid uint64, maxSpectators int) *Room {
+	now := time.Now()
+	return &Room{
+		Id:            id,
+		match:         NewMatch(id, uid),
+		spectators:    make(map[uint64]struct{}),
+		maxSpectators: maxSpectators,
+		created:       now,
+		active:        now,
+	}
+}
+
+// transition moves the room to status and logs it, r.mu must be held.
+func (r *Room) transition(logger simplelog.LogI, to Status, reason string) {
+	from := r.status
+	r.status = to
+	logger.InfoWF("room state", zap.Int64("roomId", r.Id), zap.Stringer("from", from), zap.Stringer("to", to),
+		zap.String("reason", reason))
+}
+
+func (r *Room) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// idleSince returns the last time anyone did something in the room.
+func (r *Room) idleSince() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.active
+}
+
+// Join seats uid as O while the room waits, or as a spectator when spectate is set.
+// Joining again returns the seat uid already has, Empty for spectators.
+func (r *Room) Join(logger simplelog.LogI, uid uint64, spectate bool) (Mark, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.status == Closed {
+		return Empty, ErrRoomClosed
+	}
+	if mark := r.match.MarkOf(uid); mark != Empty {
+		return mark, nil
+	}
+	if _, ok := r.spectators[uid]; ok {
+		return Empty, nil
+	}
+	r.active = time.Now()
+
+	if !spectate {
+		if r.status != Waiting {
+			return Empty, ErrRoomFull
+		}
+		if _, err := r.match.Join(uid); err != nil {
+			return Empty, err
+		}
+		r.transition(logger, Playing, "second player joined")
+		return O, nil
+	}
+	if len(r.spectators) >= r.maxSpectators {
+		return Empty, ErrRoomFull
+	}
+	r.spectators[uid] = struct{}{}
+	logger.DebugWF("room spectator joined", zap.Int64("roomId", r.Id), zap.Int("spectators", len(r.spectators)))
+	return Empty, nil
+}
+
+// Leave takes uid out of the room. The host leaving an empty room closes it, a player leaving
+// a running match forfeits it, and the room closes once both players left the finished match.
+func (r *Room) Leave(logger simplelog.LogI, uid uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.status == Closed {
+		return ErrRoomClosed
+	}
+	if _, ok := r.spectators[uid]; ok {
+		delete(r.spectators, uid)
+		return nil
+	}
+	mark := r.match.MarkOf(uid)
+	if mark == Empty {
+		return ErrNotPlayer
+	}
+	r.active = time.Now()
+
+	switch r.status {
+	case Waiting:
+		r.transition(logger, Closed, "host left")
+	case Playing:
+		if err := r.match.Forfeit(uid); err != nil {
+			return err
+		}
+		r.left[seat(mark)] = true
+		r.transition(logger, Finished, "player left")
+	case Finished:
+		r.left[seat(mark)] = true
+		if r.left[0] && r.left[1] {
+			r.transition(logger, Closed, "players left")
+		}
+	}
+	return nil
+}
+
+func seat(mark Mark) int {
+	if mark == O {
+		return 1
+	}
+	return 0
+}
+
+// Move plays cell for uid, the room finishes with the match.
+func (r *Room) Move(logger simplelog.LogI, uid uint64, cell int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.status == Closed {
+		return ErrRoomClosed
+	}
+	if err := r.match.Move(uid, cell); err != nil {
+		return err
+	}
+	r.active = time.Now()
+	if r.match.Over() {
+		r.transition(logger, Finished, "match over")
+	}
+	return nil
+}
+
+// Close ends the room whatever its status, closing twice is a no-op.
+func (r *Room) Close(logger simplelog.LogI, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.status != Closed {
+		r.transition(logger, Closed, reason)
+	}
+}
+
+// audience returns who sees the room change, players still seated and spectators.
+func (r *Room) audience() []uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	uids := make([]uint64, 0, 2+len(r.spectators))
+	x, o := r.match.Players()
+	for i, uid := range [2]uint64{x, o} {
+		if uid != 0 && !r.left[i] {
+			uids = append(uids, uid)
+		}
+	}
+	for uid := range r.spectators {
+		uids = append(uids, uid)
+	}
+	return uids
+}
+
+// RoomInfo is a snapshot of the room sent to clients.
+type RoomInfo struct {
+	Id         int64     `json:"id,string"`
+	Status     Status    `json:"status"`
+	Spectators int       `json:"spectators"`
+	Created    time.Time `json:"created"`
+	Match      *State    `json:"match"`
+}
+
+func (r *Room) Info() *RoomInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return &RoomInfo{
+		Id:         r.Id,
+		Status:     r.status,
+		Spectators: len(r.spectators),
+		Created:    r.created,
+		Match:      r.match.State(),
+	}
+}
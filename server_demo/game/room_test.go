@@ -0,0 +1,153 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+)
+
+func newTestRoom(t *testing.T, rs *Rooms, uid uint64) *Room {
+	r, err := rs.Create(&simplelog.ZapLog{}, uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRoomLifecycle(t *testing.T) {
+	l := &simplelog.ZapLog{}
+	rs := NewRooms(l, time.Minute, 1, 0)
+	r := newTestRoom(t, rs, 10)
+
+	if _, err := r.Join(l, 30, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Join(l, 40, true); !errors.Is(err, ErrRoomFull) {
+		t.Errorf("expect spectators full, got %v", err)
+	}
+	if mark, err := r.Join(l, 20, false); err != nil || mark != O || r.Status() != Playing {
+		t.Fatalf("join: %v %v %v", mark, err, r.Status())
+	}
+	if _, err := r.Join(l, 40, false); !errors.Is(err, ErrRoomFull) {
+		t.Errorf("expect seats full, got %v", err)
+	}
+	if err := r.Move(l, 30, 0); !errors.Is(err, ErrNotPlayer) {
+		t.Errorf("spectators may not move, got %v", err)
+	}
+
+	for i, cell := range []int{0, 3, 1, 4, 2} {
+		uid := uint64(10)
+		if i%2 == 1 {
+			uid = 20
+		}
+		if err := r.Move(l, uid, cell); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if r.Status() != Finished {
+		t.Fatalf("expect finished, got %v", r.Status())
+	}
+
+	_ = r.Leave(l, 10)
+	if r.Status() != Finished {
+		t.Errorf("expect finished until both left, got %v", r.Status())
+	}
+	_ = r.Leave(l, 20)
+	if r.Status() != Closed {
+		t.Errorf("expect closed, got %v", r.Status())
+	}
+	if err := r.Move(l, 10, 5); !errors.Is(err, ErrRoomClosed) {
+		t.Errorf("expect room closed, got %v", err)
+	}
+}
+
+func TestRoomHostLeaves(t *testing.T) {
+	l := &simplelog.ZapLog{}
+	r := newTestRoom(t, NewRooms(l, 0, 0, 0), 10)
+	if err := r.Leave(l, 20); !errors.Is(err, ErrNotPlayer) {
+		t.Errorf("expect not a player, got %v", err)
+	}
+	if err := r.Leave(l, 10); err != nil || r.Status() != Closed {
+		t.Errorf("expect closed, got %v %v", err, r.Status())
+	}
+}
+
+func TestRoomsReap(t *testing.T) {
+	l := &simplelog.ZapLog{}
+	rs := NewRooms(l, time.Minute, 0, 0)
+	idle := newTestRoom(t, rs, 10)
+	busy := newTestRoom(t, rs, 20)
+	closed := newTestRoom(t, rs, 30)
+	closed.Close(l, "test")
+
+	now := time.Now()
+	idle.active = now.Add(-2 * time.Minute)
+	if n := rs.Reap(now); n != 2 {
+		t.Errorf("expect idle and closed reaped, got %d", n)
+	}
+	if idle.Status() != Closed || rs.Get(idle.Id) != nil || rs.Get(busy.Id) == nil {
+		t.Errorf("unexpected rooms after reap: %v %d", idle.Status(), rs.Len())
+	}
+	if list := rs.List(Waiting); len(list) != 1 || list[0] != busy {
+		t.Errorf("expect busy in the lobby, got %v", list)
+	}
+
+	// the pusher may use the rooms, it runs outside their lock
+	rs.SetPusher(pushFunc(func(uint64, string, interface{}) int { return rs.Len() }))
+	busy.active = now.Add(-2 * time.Minute)
+	if n := rs.Reap(now); n != 1 {
+		t.Errorf("expect busy reaped, got %d", n)
+	}
+}
+
+type pushFunc func(uid uint64, msgType string, v interface{}) int
+
+func (f pushFunc) Push(uid uint64, msgType string, v interface{}) int {
+	return f(uid, msgType, v)
+}
+
+func TestRoomsReady(t *testing.T) {
+	l := &simplelog.ZapLog{}
+	rs := NewRooms(l, time.Minute, 0, 0)
+	if err := rs.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.Ready(context.Background()); err != nil {
+		t.Errorf("expect ready, got %v", err)
+	}
+	if err := rs.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.Ready(context.Background()); !errors.Is(err, ErrRoomsStopped) {
+		t.Errorf("expect not ready after stop, got %v", err)
+	}
+}
+
+func TestRoomsCreateLimits(t *testing.T) {
+	l := &simplelog.ZapLog{}
+	rs := NewRooms(l, time.Minute, 0, 2)
+	first := newTestRoom(t, rs, 10)
+	newTestRoom(t, rs, 10)
+	if _, err := rs.Create(l, 10); !errors.Is(err, ErrTooManyRooms) {
+		t.Errorf("expect too many rooms, got %v", err)
+	}
+	newTestRoom(t, rs, 20)
+
+	// a closed room no longer counts
+	first.Close(l, "test")
+	newTestRoom(t, rs, 10)
+
+	waiting := newTestRoom(t, rs, 20)
+	if err := rs.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rs.Create(l, 30); !errors.Is(err, ErrRoomsStopped) {
+		t.Errorf("expect create refused after stop, got %v", err)
+	}
+	if _, err := rs.Join(l, waiting, 30, false); !errors.Is(err, ErrRoomsStopped) {
+		t.Errorf("expect join refused after stop, got %v", err)
+	}
+}
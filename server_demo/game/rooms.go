@@ -0,0 +1,257 @@
+/*
+@Author: agent
+@Date: 2026/10/16 21:01
+@Description: room manager with idle reaping
+*/
+
+package game
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/idgen"
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultIdleTimeout   = 10 * time.Minute
+	DefaultMaxSpectators = 8
+	DefaultMaxRooms      = 3
+)
+
+var (
+	ErrRoomsStopped = errors.New("game: room manager is stopped")
+	ErrTooManyRooms = errors.New("game: too many open rooms")
+)
+
+// Pusher delivers a message to every connection of uid, process.Hub is one.
+type Pusher interface {
+	Push(uid uint64, msgType string, v interface{}) int
+}
+
+// Rooms owns every room of the process and reaps the idle ones.
+type Rooms struct {
+	logger        simplelog.LogI
+	idleTimeout   time.Duration
+	maxSpectators int
+	maxRooms      int // open rooms per uid
+
+	pusher Pusher
+
+	mu    sync.RWMutex
+	rooms map[int64]*Room
+
+	stop    chan struct{}
+	done    chan struct{}
+	stopped atomic.Bool
+}
+
+// NewRooms closes rooms nobody touched for idleTimeout and lets a uid hold maxRooms rooms not yet
+// finished, 0 uses the defaults.
+func NewRooms(l simplelog.LogI, idleTimeout time.Duration, maxSpectators, maxRooms int) *Rooms {
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultIdleTimeout
+	}
+	if maxSpectators <= 0 {
+		maxSpectators = DefaultMaxSpectators
+	}
+	if maxRooms <= 0 {
+		maxRooms = DefaultMaxRooms
+	}
+	return &Rooms{
+		logger:        l,
+		idleTimeout:   idleTimeout,
+		maxSpectators: maxSpectators,
+		maxRooms:      maxRooms,
+		rooms:         make(map[int64]*Room),
+	}
+}
+
+// SetPusher makes every change of a room reach its audience, call it before serving.
+func (rs *Rooms) SetPusher(p Pusher) {
+	rs.pusher = p
+}
+
+// notify pushes the room to everyone in it.
+func (rs *Rooms) notify(r *Room) {
+	if rs.pusher == nil {
+		return
+	}
+	info := r.Info()
+	for _, uid := range r.audience() {
+		rs.pusher.Push(uid, "room", info)
+	}
+}
+
+// Create opens a waiting room with uid as X, unless uid already hosts maxRooms waiting or
+// playing rooms.
+func (rs *Rooms) Create(logger simplelog.LogI, uid uint64) (*Room, error) {
+	rs.mu.Lock()
+	if rs.stopped.Load() {
+		rs.mu.Unlock()
+		return nil, ErrRoomsStopped
+	}
+	if n := rs.hosted(uid); n >= rs.maxRooms {
+		rs.mu.Unlock()
+		logger.InfoWF("room create refused", zap.Int("rooms", n), zap.Int("maxRooms", rs.maxRooms))
+		return nil, ErrTooManyRooms
+	}
+	r := newRoom(idgen.Next(), uid, rs.maxSpectators)
+	rs.rooms[r.Id] = r
+	rs.mu.Unlock()
+	logger.InfoWF("room state", zap.Int64("roomId", r.Id), zap.String("from", ""), zap.Stringer("to", Waiting),
+		zap.String("reason", "created"))
+	return r, nil
+}
+
+// hosted counts the waiting and playing rooms uid created, rs.mu must be held.
+func (rs *Rooms) hosted(uid uint64) int {
+	n := 0
+	for _, r := range rs.rooms {
+		if x, _ := r.match.Players(); x != uid {
+			continue
+		}
+		if s := r.Status(); s == Waiting || s == Playing {
+			n++
+		}
+	}
+	return n
+}
+
+// Join seats uid in r, or makes it a spectator, and tells the room.
+func (rs *Rooms) Join(logger simplelog.LogI, r *Room, uid uint64, spectate bool) (Mark, error) {
+	if rs.stopped.Load() {
+		return Empty, ErrRoomsStopped
+	}
+	mark, err := r.Join(logger, uid, spectate)
+	if err != nil {
+		return Empty, err
+	}
+	rs.notify(r)
+	return mark, nil
+}
+
+// Get returns the room, closed rooms stay until the next reap so clients see how they ended.
+func (rs *Rooms) Get(id int64) *Room {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.rooms[id]
+}
+
+// List returns the rooms in status, oldest first.
+func (rs *Rooms) List(status Status) []*Room {
+	rs.mu.RLock()
+	list := make([]*Room, 0, len(rs.rooms))
+	for _, r := range rs.rooms {
+		if r.Status() == status {
+			list = append(list, r)
+		}
+	}
+	rs.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Id < list[j].Id })
+	return list
+}
+
+func (rs *Rooms) Len() int {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return len(rs.rooms)
+}
+
+// Reap closes rooms idle since before now-idleTimeout and drops closed rooms.
+func (rs *Rooms) Reap(now time.Time) int {
+	var idle []*Room
+	n := 0
+	rs.mu.Lock()
+	for id, r := range rs.rooms {
+		if r.Status() != Closed && now.Sub(r.idleSince()) < rs.idleTimeout {
+			continue
+		}
+		if r.Status() != Closed {
+			r.Close(rs.logger, "idle")
+			idle = append(idle, r)
+		}
+		delete(rs.rooms, id)
+		n++
+	}
+	rs.mu.Unlock()
+
+	// pushes may block on slow connections, never under rs.mu
+	for _, r := range idle {
+		rs.notify(r)
+	}
+	return n
+}
+
+// Start runs the reaper, it is the OnStart of the rooms hook.
+func (rs *Rooms) Start() error {
+	rs.stop, rs.done = make(chan struct{}), make(chan struct{})
+	interval := rs.idleTimeout / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	go func() {
+		defer close(rs.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-rs.stop:
+				return
+			case now := <-ticker.C:
+				if n := rs.Reap(now); n > 0 {
+					rs.logger.DebugWF("rooms reaped", zap.Int("reaped", n), zap.Int("rooms", rs.Len()))
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Ready is the readiness check of the room manager, it fails once Stop began.
+func (rs *Rooms) Ready(context.Context) error {
+	if rs.stopped.Load() {
+		return ErrRoomsStopped
+	}
+	return nil
+}
+
+// Stop ends the reaper and closes every room, players get ErrRoomClosed and new rooms
+// ErrRoomsStopped until the process exits.
+func (rs *Rooms) Stop(ctx context.Context) error {
+	// under rs.mu so no Create slips in after the rooms below were closed
+	rs.mu.Lock()
+	rs.stopped.Store(true)
+	rs.mu.Unlock()
+	if rs.stop != nil {
+		close(rs.stop)
+		select {
+		case <-rs.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	var open []*Room
+	rs.mu.RLock()
+	for _, r := range rs.rooms {
+		if r.Status() != Closed {
+			r.Close(rs.logger, "shutdown")
+			open = append(open, r)
+		}
+	}
+	rs.mu.RUnlock()
+
+	for _, r := range open {
+		rs.notify(r)
+	}
+	return nil
+}
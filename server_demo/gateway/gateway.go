@@ -0,0 +1,432 @@
+/*
+@Author: agent
+@Date: 2026/10/16 21:09
+@Description: length prefixed tcp gateway for cocos native clients
+*/
+
+package gateway
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/auth"
+	"github.com/Xbzzy/client_demo/server_demo/common/buffer"
+	"github.com/Xbzzy/client_demo/server_demo/common/idgen"
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"github.com/Xbzzy/client_demo/server_demo/process"
+	"go.uber.org/zap"
+)
+
+// A frame is | length uint32 | msgId uint32 | payload |, big endian, length counts msgId and payload.
+const (
+	HeaderSize          = 8
+	DefaultMaxFrameSize = 64 << 10
+	DefaultIdleTimeout  = 60 * time.Second
+	DefaultAuthTimeout  = 10 * time.Second
+	DefaultMaxConns     = 10000
+)
+
+// message ids below MsgReserved belong to the gateway
+const (
+	MsgHeartbeat uint32 = 0 // echoed back as is
+	MsgAuth      uint32 = 1 // payload is a session token, answered with an envelope holding the uid
+	MsgReserved  uint32 = 16
+)
+
+var (
+	ErrClosed = errors.New("gateway: closed")
+
+	errTooManyConns = errors.New("gateway: too many connections")
+)
+
+// Handler runs a frame of a logged in session, payload is given back to the pool after it returns.
+type Handler func(s *Session, msgId uint32, payload buffer.IoBuffer)
+
+// Session is one tcp connection.
+type Session struct {
+	Id     int64
+	Logger simplelog.LogI // cloned from the gateway logger, the session id as log id and uid once logged in
+
+	conn   net.Conn
+	claims atomic.Pointer[auth.Claims]
+	token  string // of the last MsgAuth, only the read loop touches it
+	wmu    sync.Mutex
+	closed atomic.Bool
+}
+
+// Uid is 0 until the client sent a valid MsgAuth.
+func (s *Session) Uid() uint64 {
+	if claims := s.claims.Load(); claims != nil {
+		return claims.Uid
+	}
+	return 0
+}
+
+// Context carries the claims of MsgAuth, so UidFrom works in message handlers.
+func (s *Session) Context() context.Context {
+	return process.WithSession(context.Background(), s.claims.Load())
+}
+
+func (s *Session) RemoteAddr() net.Addr {
+	return s.conn.RemoteAddr()
+}
+
+// write sends a frame whose payload fill writes into the buffer after the header.
+func (s *Session) write(msgId uint32, fill func(buf buffer.IoBuffer) error) error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+	buf := buffer.GetIoBuffer(buffer.MinRead)
+	defer buffer.PutIoBuffer(buf)
+
+	var head [HeaderSize]byte
+	_, _ = buf.Write(head[:])
+	if err := fill(buf); err != nil {
+		return err
+	}
+	frame := buf.Bytes()
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(frame)-4))
+	binary.BigEndian.PutUint32(frame[4:8], msgId)
+
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	_ = s.conn.SetWriteDeadline(time.Now().Add(buffer.DefaultConnWriteTimeout))
+	_, err := buf.WriteTo(s.conn)
+	return err
+}
+
+// Send writes payload as one frame, it is safe to call from any goroutine.
+func (s *Session) Send(msgId uint32, payload []byte) error {
+	return s.write(msgId, func(buf buffer.IoBuffer) error {
+		_, err := buf.Write(payload)
+		return err
+	})
+}
+
+// SendJson writes v as the json payload of one frame.
+func (s *Session) SendJson(msgId uint32, v interface{}) error {
+	return s.write(msgId, func(buf buffer.IoBuffer) error {
+		return json.NewEncoder(buf).Encode(v)
+	})
+}
+
+// Reply answers msgId with the envelope http clients get, err picks the code like process.WriteError.
+func (s *Session) Reply(logger simplelog.LogI, msgId uint32, data interface{}, err error) error {
+	env := process.Envelope{Code: process.CodeOk, Msg: "ok", LogId: logger.GetLogId(), Data: data}
+	if err != nil {
+		var e *process.Error
+		if !errors.As(err, &e) {
+			logger.ErrorWF("tcp handler error", zap.Uint32("msgId", msgId), zap.Error(err))
+			e = process.NewError(http.StatusInternalServerError, "internal error")
+		}
+		env = process.Envelope{Code: e.Code, Msg: e.Msg, LogId: logger.GetLogId()}
+	}
+	return s.SendJson(msgId, env)
+}
+
+// Close drops the connection, the read loop then ends.
+func (s *Session) Close() {
+	if s.closed.CompareAndSwap(false, true) {
+		_ = s.conn.Close()
+	}
+}
+
+// Gateway accepts tcp clients and routes their frames by message id.
+type Gateway struct {
+	MaxFrameSize int
+	IdleTimeout  time.Duration // a client sending nothing, not even a heartbeat, for this long is dropped
+	AuthTimeout  time.Duration // a client not logged in this long after connecting is dropped, heartbeats or not
+	MaxConns     int           // clients accepted beyond this are closed right away
+
+	logger simplelog.LogI
+	verify func(token string) (*auth.Claims, error)
+
+	mu       sync.RWMutex
+	handlers map[uint32]Handler
+	sessions map[int64]*Session
+	ln       net.Listener
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// New creates a gateway, verify checks the token of MsgAuth (process.Auth.Verify).
+func New(l simplelog.LogI, verify func(token string) (*auth.Claims, error)) *Gateway {
+	return &Gateway{
+		MaxFrameSize: DefaultMaxFrameSize,
+		IdleTimeout:  DefaultIdleTimeout,
+		AuthTimeout:  DefaultAuthTimeout,
+		MaxConns:     DefaultMaxConns,
+		logger:       l,
+		verify:       verify,
+		handlers:     make(map[uint32]Handler),
+		sessions:     make(map[int64]*Session),
+	}
+}
+
+// Handle routes msgId to h, registering a reserved or taken id panics like a duplicate route.
+func (g *Gateway) Handle(msgId uint32, h Handler) {
+	if msgId < MsgReserved {
+		panic("gateway: message id is reserved")
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.handlers[msgId]; ok {
+		panic("gateway: duplicate handler")
+	}
+	g.handlers[msgId] = h
+}
+
+// Mount routes every message of d to the gateway, payloads are json and answered with the
+// envelope of Dispatch. Call it after the messages are registered.
+func (g *Gateway) Mount(d *process.Dispatcher) {
+	for _, msgId := range d.Ids() {
+		g.Handle(msgId, func(s *Session, msgId uint32, payload buffer.IoBuffer) {
+			_ = s.SendJson(msgId, d.Dispatch(s.Context(), s.Logger, msgId, payload.Bytes()))
+		})
+	}
+}
+
+// Serve accepts on ln until Shutdown, then it returns ErrClosed.
+func (g *Gateway) Serve(ln net.Listener) error {
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		_ = ln.Close()
+		return ErrClosed
+	}
+	g.ln = ln
+	g.mu.Unlock()
+
+	var delay time.Duration
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			g.mu.RLock()
+			closed := g.closed
+			g.mu.RUnlock()
+			if closed {
+				return ErrClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				// out of fds and the like, back off like net/http does
+				delay = min(max(2*delay, 5*time.Millisecond), time.Second)
+				g.logger.WarnWF("tcp accept fail", zap.Error(err), zap.Duration("retryIn", delay))
+				time.Sleep(delay)
+				continue
+			}
+			return err
+		}
+		delay = 0
+
+		s := &Session{Id: idgen.Next(), Logger: g.logger.Clone(), conn: conn}
+		s.Logger.SetLogId(s.Id)
+		if err = g.add(s); err != nil {
+			_ = conn.Close()
+			if errors.Is(err, ErrClosed) {
+				return ErrClosed
+			}
+			s.Logger.WarnWF("tcp conn refused", zap.String("remoteAddr", conn.RemoteAddr().String()),
+				zap.Int("maxConns", g.MaxConns))
+			continue
+		}
+		go g.serveConn(s)
+	}
+}
+
+func (g *Gateway) add(s *Session) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return ErrClosed
+	}
+	if len(g.sessions) >= g.MaxConns {
+		return errTooManyConns
+	}
+	g.sessions[s.Id] = s
+	g.wg.Add(1)
+	return nil
+}
+
+func (g *Gateway) remove(s *Session) {
+	g.mu.Lock()
+	delete(g.sessions, s.Id)
+	g.mu.Unlock()
+	g.wg.Done()
+}
+
+// Count returns the number of connected clients.
+func (g *Gateway) Count() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return len(g.sessions)
+}
+
+// Shutdown stops accepting, drops every client and waits for running handlers until ctx is done.
+func (g *Gateway) Shutdown(ctx context.Context) error {
+	g.mu.Lock()
+	g.closed = true
+	if g.ln != nil {
+		_ = g.ln.Close()
+	}
+	for _, s := range g.sessions {
+		s.Close()
+	}
+	g.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// serveConn reads frames with IoBuffer.ReadOnce, which waits buffer.ConnReadTimeout for the
+// first byte and then drains what the kernel has, so a quiet client shows up as a timeout.
+func (g *Gateway) serveConn(s *Session) {
+	defer g.remove(s)
+	defer s.Close()
+
+	rbuf := buffer.GetIoBuffer(buffer.MinRead)
+	defer buffer.PutIoBuffer(rbuf)
+
+	start, last := time.Now(), time.Now()
+	s.Logger.InfoWF("tcp session start", zap.String("remoteAddr", s.conn.RemoteAddr().String()))
+	// heartbeats keep a session from going idle, only MsgAuth stops this
+	authTimer := time.AfterFunc(g.AuthTimeout, func() {
+		if s.Uid() == 0 {
+			s.Logger.InfoWF("tcp auth timeout", zap.Duration("timeout", g.AuthTimeout))
+			s.Close()
+		}
+	})
+	defer authTimer.Stop()
+	for {
+		n, err := rbuf.ReadOnce(s.conn)
+		if n > 0 {
+			last = time.Now()
+			if reason := g.dispatchFrames(s, rbuf); reason != "" {
+				s.Logger.InfoWF("tcp session end", zap.String("reason", reason), zap.Duration("cost", time.Since(start)))
+				return
+			}
+		}
+		if err == nil {
+			continue
+		}
+
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() && time.Since(last) < g.IdleTimeout {
+			continue
+		}
+		s.Logger.InfoWF("tcp session end", zap.Error(err), zap.Duration("idle", time.Since(last)),
+			zap.Duration("cost", time.Since(start)))
+		return
+	}
+}
+
+// dispatchFrames runs every whole frame in rbuf, a non empty reason ends the session.
+func (g *Gateway) dispatchFrames(s *Session, rbuf buffer.IoBuffer) string {
+	for rbuf.Len() >= HeaderSize {
+		head := rbuf.Peek(HeaderSize)
+		length := binary.BigEndian.Uint32(head[0:4])
+		if length < 4 || int64(length)-4 > int64(g.MaxFrameSize) {
+			s.Logger.InfoWF("tcp bad frame length", zap.Uint32("length", length), zap.Int("max", g.MaxFrameSize))
+			return "bad frame"
+		}
+		size := 4 + int(length)
+		if rbuf.Len() < size {
+			return ""
+		}
+		msgId := binary.BigEndian.Uint32(head[4:8])
+
+		payload := buffer.GetIoBuffer(size - HeaderSize)
+		_, _ = payload.Write(rbuf.Bytes()[HeaderSize:size])
+		rbuf.Drain(size)
+		g.dispatch(s, msgId, payload)
+		_ = buffer.PutIoBuffer(payload)
+
+		if s.closed.Load() {
+			return "closed"
+		}
+	}
+	return ""
+}
+
+// dispatch runs one frame, a panicking handler is logged and answered with a 500 envelope. A
+// session whose token expired or was revoked since MsgAuth gets a 401 and is closed.
+func (g *Gateway) dispatch(s *Session, msgId uint32, payload buffer.IoBuffer) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			s.Logger.ErrorWF("tcp handler panic", zap.Uint32("msgId", msgId), zap.Any("panic", rec),
+				zap.ByteString("stack", debug.Stack()))
+			_ = s.Reply(s.Logger, msgId, nil, process.NewError(http.StatusInternalServerError, "internal error"))
+		}
+	}()
+
+	switch msgId {
+	case MsgHeartbeat:
+		_ = s.Send(MsgHeartbeat, payload.Bytes())
+		return
+	case MsgAuth:
+		g.login(s, payload.String())
+		return
+	}
+
+	if s.Uid() == 0 {
+		_ = s.Reply(s.Logger, msgId, nil, process.Unauthorized("login required"))
+		return
+	}
+	// the token may have expired or been revoked since MsgAuth
+	if _, err := g.verify(s.token); err != nil {
+		s.Logger.InfoWF("tcp session token rejected", zap.Uint32("msgId", msgId), zap.Error(err))
+		msg := "invalid token"
+		if errors.Is(err, auth.ErrExpired) {
+			msg = "token expired"
+		}
+		_ = s.Reply(s.Logger, msgId, nil, process.Unauthorized(msg))
+		s.Close()
+		return
+	}
+	g.mu.RLock()
+	h := g.handlers[msgId]
+	g.mu.RUnlock()
+	if h == nil {
+		s.Logger.InfoWF("tcp unknown message", zap.Uint32("msgId", msgId), zap.Int("len", payload.Len()))
+		_ = s.Reply(s.Logger, msgId, nil, process.NotFound("unknown message"))
+		return
+	}
+	h(s, msgId, payload)
+}
+
+func (g *Gateway) login(s *Session, token string) {
+	claims, err := g.verify(token)
+	if err != nil {
+		s.Logger.InfoWF("tcp auth rejected", zap.Error(err))
+		_ = s.Reply(s.Logger, MsgAuth, nil, process.Unauthorized("invalid token"))
+		return
+	}
+	if uid := s.Uid(); uid != 0 && uid != claims.Uid {
+		_ = s.Reply(s.Logger, MsgAuth, nil, process.Conflict("already logged in as another uid"))
+		return
+	}
+	s.token = token
+	s.claims.Store(claims)
+	s.Logger.SetUid(claims.Uid)
+	s.Logger.InfoWF("tcp auth ok", zap.Int64("tokenId", claims.Id))
+	_ = s.Reply(s.Logger, MsgAuth, map[string]string{"uid": strconv.FormatUint(claims.Uid, 10)}, nil)
+}
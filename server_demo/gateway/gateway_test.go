@@ -0,0 +1,286 @@
+package gateway
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/auth"
+	"github.com/Xbzzy/client_demo/server_demo/common/buffer"
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"github.com/Xbzzy/client_demo/server_demo/process"
+)
+
+const (
+	msgEcho  uint32 = 100
+	msgPanic uint32 = 101
+)
+
+// newTestGateway serves on a local port, opts tune the gateway before it serves.
+func newTestGateway(t *testing.T, idleTimeout time.Duration, opts ...func(g *Gateway)) (*Gateway, *auth.Signer, string) {
+	signer, err := auth.NewSigner([]byte("0123456789abcdef"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := New(&simplelog.ZapLog{}, signer.Verify)
+	g.MaxFrameSize = 1 << 10
+	if idleTimeout > 0 {
+		g.IdleTimeout = idleTimeout
+	}
+	g.Handle(msgEcho, func(s *Session, msgId uint32, payload buffer.IoBuffer) {
+		_ = s.Reply(s.Logger, msgId, map[string]interface{}{"uid": s.Uid(), "echo": payload.String()}, nil)
+	})
+	g.Handle(msgPanic, func(*Session, uint32, buffer.IoBuffer) {
+		panic("boom")
+	})
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- g.Serve(ln) }()
+	t.Cleanup(func() {
+		if err := g.Shutdown(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := <-served; !errors.Is(err, ErrClosed) {
+			t.Errorf("expect ErrClosed from Serve, got %v", err)
+		}
+	})
+	return g, signer, ln.Addr().String()
+}
+
+func dial(t *testing.T, addr string) net.Conn {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func send(conn net.Conn, msgId uint32, payload []byte) {
+	frame := make([]byte, HeaderSize, HeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(4+len(payload)))
+	binary.BigEndian.PutUint32(frame[4:8], msgId)
+	_, _ = conn.Write(append(frame, payload...))
+}
+
+func recv(t *testing.T, conn net.Conn) (uint32, []byte) {
+	var head [HeaderSize]byte
+	if _, err := io.ReadFull(conn, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(head[0:4])-4)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		t.Fatal(err)
+	}
+	return binary.BigEndian.Uint32(head[4:8]), payload
+}
+
+func recvEnvelope(t *testing.T, conn net.Conn, data interface{}) (uint32, process.Envelope) {
+	msgId, payload := recv(t, conn)
+	env := process.Envelope{Data: data}
+	if err := json.Unmarshal(payload, &env); err != nil {
+		t.Fatalf("bad envelope %q: %v", payload, err)
+	}
+	return msgId, env
+}
+
+func TestGatewayDispatch(t *testing.T) {
+	_, signer, addr := newTestGateway(t, 0)
+	conn := dial(t, addr)
+
+	send(conn, MsgHeartbeat, []byte("t1"))
+	if msgId, payload := recv(t, conn); msgId != MsgHeartbeat || string(payload) != "t1" {
+		t.Errorf("unexpected heartbeat %d %q", msgId, payload)
+	}
+
+	send(conn, msgEcho, []byte("hi"))
+	if _, env := recvEnvelope(t, conn, nil); env.Code != 401 {
+		t.Errorf("expect 401 before auth, got %+v", env)
+	}
+	send(conn, MsgAuth, []byte("bad"))
+	if _, env := recvEnvelope(t, conn, nil); env.Code != 401 {
+		t.Errorf("expect 401 for a bad token, got %+v", env)
+	}
+
+	token, _, _ := signer.Issue(42, "test")
+	send(conn, MsgAuth, []byte(token))
+	var login map[string]string
+	if msgId, env := recvEnvelope(t, conn, &login); msgId != MsgAuth || env.Code != 0 || login["uid"] != "42" {
+		t.Fatalf("unexpected auth answer %d %+v", msgId, env)
+	}
+
+	// two frames in one write and a frame split over two writes
+	send(conn, msgEcho, []byte("a"))
+	send(conn, msgEcho, []byte("b"))
+	frame := []byte{0, 0, 0, 5, 0, 0, 0, byte(msgEcho), 'c'}
+	_, _ = conn.Write(frame[:3])
+	time.Sleep(20 * time.Millisecond)
+	_, _ = conn.Write(frame[3:])
+	for _, want := range []string{"a", "b", "c"} {
+		var echo struct {
+			Uid  uint64 `json:"uid"`
+			Echo string `json:"echo"`
+		}
+		if msgId, env := recvEnvelope(t, conn, &echo); msgId != msgEcho || env.LogId == 0 || echo.Uid != 42 || echo.Echo != want {
+			t.Errorf("expect echo %q, got %d %+v %+v", want, msgId, env, echo)
+		}
+	}
+
+	send(conn, 999, nil)
+	if _, env := recvEnvelope(t, conn, nil); env.Code != 404 {
+		t.Errorf("expect 404 for unknown message, got %+v", env)
+	}
+	send(conn, msgPanic, nil)
+	if _, env := recvEnvelope(t, conn, nil); env.Code != 500 {
+		t.Errorf("expect 500 after panic, got %+v", env)
+	}
+	send(conn, MsgHeartbeat, nil)
+	if msgId, _ := recv(t, conn); msgId != MsgHeartbeat {
+		t.Error("expect the session to survive a panic")
+	}
+}
+
+func TestGatewayFrameTooLarge(t *testing.T) {
+	_, _, addr := newTestGateway(t, 0)
+	conn := dial(t, addr)
+	send(conn, msgEcho, make([]byte, 2<<10))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("expect too large frame to drop the connection")
+	}
+}
+
+func TestGatewayIdle(t *testing.T) {
+	old := buffer.ConnReadTimeout
+	buffer.ConnReadTimeout = 20 * time.Millisecond
+	// restored after the gateway shut down, cleanups run last in first out
+	t.Cleanup(func() { buffer.ConnReadTimeout = old })
+
+	_, _, addr := newTestGateway(t, 50*time.Millisecond)
+	conn := dial(t, addr)
+	start := time.Now()
+	if _, err := conn.Read(make([]byte, 1)); err == nil || time.Since(start) > 2*time.Second {
+		t.Errorf("expect idle client dropped, got %v after %v", err, time.Since(start))
+	}
+}
+
+func TestGatewayAuthTimeout(t *testing.T) {
+	_, signer, addr := newTestGateway(t, 0, func(g *Gateway) { g.AuthTimeout = 100 * time.Millisecond })
+
+	// logged in sessions stay
+	conn := dial(t, addr)
+	token, _, _ := signer.Issue(42, "test")
+	send(conn, MsgAuth, []byte(token))
+	recvEnvelope(t, conn, nil)
+
+	// heartbeats alone do not
+	anon := dial(t, addr)
+	start := time.Now()
+	for time.Since(start) < time.Second {
+		send(anon, MsgHeartbeat, nil)
+		if _, err := io.ReadFull(anon, make([]byte, HeaderSize)); err != nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if time.Since(start) >= time.Second {
+		t.Error("expect a session without MsgAuth dropped")
+	}
+
+	send(conn, MsgHeartbeat, nil)
+	if msgId, _ := recv(t, conn); msgId != MsgHeartbeat {
+		t.Error("expect the logged in session to stay")
+	}
+}
+
+func TestGatewayRecheckToken(t *testing.T) {
+	revoked := auth.NewRevocation(time.Hour)
+	_, signer, addr := newTestGateway(t, 0, func(g *Gateway) {
+		verify := g.verify
+		g.verify = func(token string) (*auth.Claims, error) {
+			claims, err := verify(token)
+			if err == nil && revoked.Revoked(claims) {
+				return claims, auth.ErrRevoked
+			}
+			return claims, err
+		}
+	})
+	conn := dial(t, addr)
+	token, claims, _ := signer.Issue(42, "test")
+	send(conn, MsgAuth, []byte(token))
+	recvEnvelope(t, conn, nil)
+	send(conn, msgEcho, []byte("a"))
+	if _, env := recvEnvelope(t, conn, nil); env.Code != 0 {
+		t.Fatalf("expect echo, got %+v", env)
+	}
+
+	revoked.Revoke(claims)
+	send(conn, msgEcho, []byte("b"))
+	if _, env := recvEnvelope(t, conn, nil); env.Code != 401 {
+		t.Errorf("expect 401 once revoked, got %+v", env)
+	}
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("expect the session closed after a revoked token")
+	}
+}
+
+func TestGatewayMaxConns(t *testing.T) {
+	g, _, addr := newTestGateway(t, 0, func(g *Gateway) { g.MaxConns = 1 })
+	conn := dial(t, addr)
+	send(conn, MsgHeartbeat, nil)
+	recv(t, conn)
+
+	extra := dial(t, addr)
+	if _, err := extra.Read(make([]byte, 1)); err == nil {
+		t.Error("expect a connection over MaxConns closed")
+	}
+	if n := g.Count(); n != 1 {
+		t.Errorf("expect 1 session, got %d", n)
+	}
+}
+
+func TestGatewayMount(t *testing.T) {
+	g, signer, addr := newTestGateway(t, 0)
+	d := process.NewDispatcher()
+	process.HandleMsg(d, 200, "Whoami", func(ctx context.Context, _ simplelog.LogI, req *struct{ Name string }) (string, error) {
+		return req.Name + ":" + strconv.FormatUint(process.UidFrom(ctx), 10), nil
+	})
+	g.Mount(d)
+
+	conn := dial(t, addr)
+	token, _, _ := signer.Issue(42, "test")
+	send(conn, MsgAuth, []byte(token))
+	recvEnvelope(t, conn, nil)
+
+	send(conn, 200, []byte(`{"Name":"x"}`))
+	var who string
+	if msgId, env := recvEnvelope(t, conn, &who); msgId != 200 || env.Code != 0 || who != "x:42" {
+		t.Errorf("unexpected answer %d %+v %q", msgId, env, who)
+	}
+	send(conn, 200, []byte(`{`))
+	if _, env := recvEnvelope(t, conn, nil); env.Code != 400 {
+		t.Errorf("expect 400 for a bad payload, got %+v", env)
+	}
+}
+
+func TestHandleReserved(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expect panic for a reserved id")
+		}
+	}()
+	New(&simplelog.ZapLog{}, nil).Handle(MsgAuth, func(*Session, uint32, buffer.IoBuffer) {})
+}
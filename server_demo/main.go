@@ -7,20 +7,206 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"github.com/Xbzzy/client_demo/server_demo/common/auth"
+	"github.com/Xbzzy/client_demo/server_demo/common/config"
+	"github.com/Xbzzy/client_demo/server_demo/common/idgen"
 	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"github.com/Xbzzy/client_demo/server_demo/game"
+	"github.com/Xbzzy/client_demo/server_demo/gateway"
 	"github.com/Xbzzy/client_demo/server_demo/process"
+	"github.com/Xbzzy/client_demo/server_demo/shop"
+	"go.uber.org/zap"
+	"net"
 	"net/http"
+	"os"
+	"strings"
 )
 
 var GLogger simplelog.LogI
 
 func main() {
-	GLogger = simplelog.InitZapLog("debug", "./", "output", 1, 7)
+	configPath := flag.String("config", "", "ini config file, empty uses defaults and env overrides")
+	flag.Parse()
 
-	process.InitHttp(GLogger)
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		panic("load config err:" + err.Error())
+	}
+
+	if err = idgen.SetNode(cfg.Server.NodeId); err != nil {
+		panic("id generator err:" + err.Error())
+	}
+
+	GLogger = simplelog.InitZapLogConfig(&cfg.Log)
+	simplelog.SetDefault(GLogger)
+	process.RegisterLogMetrics(GLogger)
+
+	lc := process.NewLifecycle(GLogger)
+	lc.Append(process.Hook{
+		Name: "logger",
+		OnStop: func(ctx context.Context) error {
+			return GLogger.Close()
+		},
+	})
+
+	router := process.InitHttp(GLogger)
+	router.SetBodyLimit(cfg.Server.MaxBodyBytes)
+	router.SetSlowThreshold(cfg.Server.SlowThreshold)
+
+	if len(cfg.Cors.AllowOrigins) > 0 {
+		router.Use(process.CorsMiddleware(process.CorsConfig{
+			AllowOrigins:     cfg.Cors.AllowOrigins,
+			AllowMethods:     cfg.Cors.AllowMethods,
+			AllowHeaders:     cfg.Cors.AllowHeaders,
+			AllowCredentials: cfg.Cors.AllowCredentials,
+			MaxAge:           cfg.Cors.MaxAge,
+		}))
+	}
+
+	secret := []byte(cfg.Auth.Secret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		_, _ = rand.Read(secret)
+		GLogger.WarnWF("auth.secret is empty, using a random one, sessions end on restart")
+	}
+	signer, err := auth.NewSigner(secret, cfg.Auth.TokenTTL)
+	if err != nil {
+		panic("auth err:" + err.Error())
+	}
+	accounts, err := cfg.Auth.Accounts()
+	if err != nil {
+		panic("auth err:" + err.Error())
+	}
+	playerAuth := process.NewAuth(signer, accounts)
+	router.Use(playerAuth.Middleware)
+	playerAuth.Register(router)
+	shop.NewShop().Register(router)
+	// rooms stop before the hub so their closing pushes still go out
+	lc.Append(process.Hook{Name: "ws", OnStop: process.DefaultHub.Close})
+	rooms := game.NewRooms(GLogger, cfg.Game.RoomIdleTimeout, cfg.Game.MaxSpectators, cfg.Game.MaxRoomsPerUid)
+	rooms.SetPusher(process.DefaultHub)
+	rooms.Register(GLogger)
+	rooms.RegisterMsgs(process.DefaultDispatcher)
+	lc.Append(process.Hook{Name: "rooms", OnStart: rooms.Start, OnStop: rooms.Stop})
+
+	health := process.NewHealth(lc.Stopping)
+	health.Readiness("rooms", rooms.Ready)
+	if !cfg.Log.ToStdOut {
+		health.Readiness("log_dir", process.DirWritable(cfg.Log.LogDir))
+	}
+	health.Register(router)
+	lc.SetDrainDelay(cfg.Server.DrainDelay)
+
+	ipLimiter := process.NewRateLimiter(GLogger, "ip", cfg.RateLimit.IpRate, cfg.RateLimit.IpBurst, process.KeyByIP)
+	uidLimiter := process.NewRateLimiter(GLogger, "uid", cfg.RateLimit.UidRate, cfg.RateLimit.UidBurst, process.KeyByUid)
+	router.Use(ipLimiter.Middleware, uidLimiter.Middleware)
+	// appended before the http server so they stop after it and log the last denials
+	lc.Append(process.Hook{Name: "ratelimit_ip", OnStart: ipLimiter.Start, OnStop: ipLimiter.Stop})
+	lc.Append(process.Hook{Name: "ratelimit_uid", OnStart: uidLimiter.Start, OnStop: uidLimiter.Stop})
+	if cfg.Server.CompressMinSize > 0 {
+		router.Use(process.CompressMiddleware(cfg.Server.CompressMinSize))
+	}
+
+	for _, mount := range cfg.Static.Mounts {
+		prefix, dir, _ := strings.Cut(mount, "=")
+		site, err := process.NewStaticSite(prefix, os.DirFS(dir))
+		if err != nil {
+			GLogger.WarnWF("static site skipped", zap.String("prefix", prefix), zap.String("dir", dir), zap.Error(err))
+			continue
+		}
+		router.Static(site)
+	}
+
+	srv := &http.Server{
+		Addr:              cfg.Server.ListenAddr,
+		Handler:           router,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+	}
+	lc.AppendHttpServer("http", srv)
+
+	if cfg.Gateway.ListenAddr != "" {
+		gw := gateway.New(GLogger, playerAuth.Verify)
+		gw.MaxFrameSize = cfg.Gateway.MaxFrameSize
+		gw.IdleTimeout = cfg.Gateway.IdleTimeout
+		gw.AuthTimeout = cfg.Gateway.AuthTimeout
+		gw.MaxConns = cfg.Gateway.MaxConns
+		gw.Mount(process.DefaultDispatcher)
+		lc.Append(process.Hook{
+			Name: "gateway",
+			OnStart: func() error {
+				ln, err := net.Listen("tcp", cfg.Gateway.ListenAddr)
+				if err != nil {
+					return err
+				}
+				GLogger.InfoWF("gateway listen", zap.String("addr", ln.Addr().String()))
+				go func() {
+					if err := gw.Serve(ln); err != nil && !errors.Is(err, gateway.ErrClosed) {
+						lc.Fail(err)
+					}
+				}()
+				return nil
+			},
+			OnStop: gw.Shutdown,
+		})
+	}
+
+	if adminLogger, ok := GLogger.(process.AdminLogger); ok && cfg.Admin.ListenAddr != "" {
+		adminSrv := &http.Server{
+			Addr:              cfg.Admin.ListenAddr,
+			Handler:           process.InitAdmin(adminLogger, playerAuth),
+			ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		}
+		lc.AppendHttpServer("admin", adminSrv)
+	}
+
+	reloader := config.NewReloader(*configPath, cfg)
+	reloader.OnChange(func(c *config.Config) error {
+		GLogger.SetLogLevel(c.Log.LogLevel)
+		return nil
+	}, "log.log_level")
+	reloader.OnChange(func(c *config.Config) error {
+		router.SetBodyLimit(c.Server.MaxBodyBytes)
+		router.SetSlowThreshold(c.Server.SlowThreshold)
+		return nil
+	}, "server.max_body_bytes", "server.slow_threshold")
+	reloader.OnChange(func(c *config.Config) error {
+		accounts, err := c.Auth.Accounts()
+		if err != nil {
+			return err
+		}
+		playerAuth.SetAccounts(accounts)
+		return nil
+	}, "auth.operators", "auth.shop_staff")
+	reloader.OnChange(func(c *config.Config) error {
+		ipLimiter.SetRate(c.RateLimit.IpRate, c.RateLimit.IpBurst)
+		uidLimiter.SetRate(c.RateLimit.UidRate, c.RateLimit.UidBurst)
+		return nil
+	}, "ratelimit.ip_rate", "ratelimit.ip_burst", "ratelimit.uid_rate", "ratelimit.uid_burst")
+	if zl, ok := GLogger.(*simplelog.ZapLog); ok {
+		// a new interval starts with a new file, otherwise files are only rotated on schedule or
+		// through the admin rotate route. log_dir and log_name need a restart.
+		reloader.OnChange(func(c *config.Config) error {
+			zl.SetRotateMinute(c.Log.Minute)
+			if err := zl.Rotate(); err != nil {
+				GLogger.WarnWF("reload rotate log fail", zap.Error(err))
+			}
+			return nil
+		}, "log.minute")
+	}
+	lc.OnReload(func() {
+		_ = reloader.Reload(GLogger)
+	})
 
-	err := http.ListenAndServe(":5999", nil)
+	err = lc.Run(cfg.Server.ShutdownTimeout)
 	if err != nil {
-		panic("http server start err:" + err.Error())
+		panic("http server err:" + err.Error())
 	}
 }
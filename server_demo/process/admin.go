@@ -0,0 +1,161 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:39
+@Description: admin routes for the log, served on the admin listener only
+*/
+
+package process
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/auth"
+	"github.com/Xbzzy/client_demo/server_demo/common/metrics"
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// AdminLogger is what the admin routes need besides simplelog.LogI, *simplelog.ZapLog implements it.
+type AdminLogger interface {
+	simplelog.LogI
+	Rotate() error
+	SetDebugUid(uid uint64, until time.Time)
+	RemoveDebugUid(uid uint64)
+	DebugUids() map[uint64]time.Time
+}
+
+type logLevelBody struct {
+	Level string `json:"level"`
+}
+
+type debugUidBody struct {
+	Uid   uint64     `json:"uid,string"`
+	Until *time.Time `json:"until,omitempty"` // nil never expires
+}
+
+func newDebugUidBody(uid uint64, until time.Time) debugUidBody {
+	body := debugUidBody{Uid: uid}
+	if !until.IsZero() {
+		body.Until = &until
+	}
+	return body
+}
+
+type auditLogger interface {
+	AuditWF(msg string, fields ...zapcore.Field)
+}
+
+// Audit writes msg regardless of the log level when the logger supports it.
+func Audit(logger simplelog.LogI, msg string, fields ...zapcore.Field) {
+	if al, ok := logger.(auditLogger); ok {
+		al.AuditWF(msg, fields...)
+		return
+	}
+	logger.WarnWF(msg, fields...)
+}
+
+// AuditMiddleware writes one audit line per call with who called what and the result.
+func AuditMiddleware(route *Route, next HttpHandler) HttpHandler {
+	return func(logger simplelog.LogI, params Params, w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next(logger, params, w, r)
+
+		status, _ := Status(w)
+		Audit(logger, "audit", zap.String("method", r.Method), zap.String("pattern", route.Pattern),
+			zap.String("path", r.URL.Path), zap.String("query", logQuery(r.URL)), zap.Any("params", params),
+			zap.String("remoteAddr", r.RemoteAddr), zap.Int("status", status), zap.Duration("cost", time.Since(start)))
+	}
+}
+
+func (b *logLevelBody) Validate() error {
+	_, err := zapcore.ParseLevel(b.Level)
+	return err
+}
+
+func parseUid(params Params) (uint64, error) {
+	uid, err := strconv.ParseUint(params.Get("uid"), 10, 64)
+	if err != nil || uid == 0 {
+		return 0, BadRequest("bad uid")
+	}
+	return uid, nil
+}
+
+// InitAdmin builds the admin router, it must only be served on the admin listen address.
+// Every route needs an operator session from a, which also audits each call.
+func InitAdmin(l AdminLogger, a *Auth) *Router {
+	router := NewRouter(l)
+	router.Use(a.Middleware)
+	operator := RequireRoles(auth.RoleOperator)
+
+	router.GET("/admin/log/level", JsonHandler(func(_ context.Context, _ simplelog.LogI, _ Params, _ *struct{}) (*logLevelBody, error) {
+		return &logLevelBody{Level: l.GetLogLevel()}, nil
+	}), operator)
+
+	router.PUT("/admin/log/level", JsonHandler(func(_ context.Context, logger simplelog.LogI, _ Params, req *logLevelBody) (*logLevelBody, error) {
+		old := l.GetLogLevel()
+		l.SetLogLevel(req.Level)
+		Audit(logger, "admin set log level", zap.String("old", old), zap.String("new", l.GetLogLevel()))
+		return &logLevelBody{Level: l.GetLogLevel()}, nil
+	}), operator)
+
+	router.GET("/admin/log/debug-uids", JsonHandler(func(_ context.Context, _ simplelog.LogI, _ Params, _ *struct{}) ([]debugUidBody, error) {
+		uids := l.DebugUids()
+		list := make([]debugUidBody, 0, len(uids))
+		for uid, until := range uids {
+			list = append(list, newDebugUidBody(uid, until))
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Uid < list[j].Uid })
+		return list, nil
+	}), operator)
+
+	// PUT /admin/log/debug-uids/10001?ttl=30m writes every debug log of uid 10001 for 30 minutes,
+	// from the point auth knows the uid, the "start http" line is not one of them
+	router.PUT("/admin/log/debug-uids/{uid}", func(logger simplelog.LogI, params Params, w http.ResponseWriter, r *http.Request) {
+		uid, err := parseUid(params)
+		if err != nil {
+			WriteError(logger, w, err)
+			return
+		}
+
+		var until time.Time
+		if ttl := r.URL.Query().Get("ttl"); ttl != "" {
+			d, err := time.ParseDuration(ttl)
+			if err != nil || d <= 0 {
+				WriteError(logger, w, BadRequest("bad ttl"))
+				return
+			}
+			until = time.Now().Add(d)
+		}
+
+		l.SetDebugUid(uid, until)
+		WriteJson(logger, w, newDebugUidBody(uid, until))
+	}, operator)
+
+	router.DELETE("/admin/log/debug-uids/{uid}", JsonHandler(func(_ context.Context, _ simplelog.LogI, params Params, _ *struct{}) (interface{}, error) {
+		uid, err := parseUid(params)
+		if err != nil {
+			return nil, err
+		}
+		l.RemoveDebugUid(uid)
+		return nil, nil
+	}), operator)
+
+	router.POST("/admin/log/rotate", JsonHandler(func(_ context.Context, _ simplelog.LogI, _ Params, _ *struct{}) (interface{}, error) {
+		if err := l.Rotate(); err != nil {
+			return nil, NewError(http.StatusInternalServerError, "rotate fail: "+err.Error())
+		}
+		return nil, nil
+	}), operator)
+
+	registerDebug(router, operator)
+
+	// scrapers have no session, the admin listener is internal only
+	router.GET("/admin/metrics", metricsHandler(metrics.Default))
+
+	return router
+}
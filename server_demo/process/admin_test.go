@@ -0,0 +1,85 @@
+package process
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/auth"
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+)
+
+func newTestAdminLogger(t *testing.T) AdminLogger {
+	l := simplelog.InitZapLogConfig(&simplelog.LogConfig{ToStdOut: true, LogLevel: "error"})
+	return l.(AdminLogger)
+}
+
+// newTestAdmin returns the admin router and a token of an operator.
+func newTestAdmin(t *testing.T, l AdminLogger) (*Router, string) {
+	signer, err := auth.NewSigner([]byte("0123456789abcdef"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, _ := signer.Issue(1, "test", auth.RoleOperator)
+	return InitAdmin(l, NewAuth(signer, nil)), token
+}
+
+func TestAdminLogLevel(t *testing.T) {
+	l := newTestAdminLogger(t)
+	admin, token := newTestAdmin(t, l)
+
+	w := authRequest(admin, http.MethodPut, "/admin/log/level", token, `{"level":"warn"}`)
+	if w.Code != http.StatusOK || l.GetLogLevel() != "warn" {
+		t.Fatalf("expect level warn, got %d %s", w.Code, l.GetLogLevel())
+	}
+
+	w = authRequest(admin, http.MethodGet, "/admin/log/level", token, "")
+	var body logLevelBody
+	if err := json.Unmarshal(w.Body.Bytes(), &Envelope{Data: &body}); err != nil || body.Level != "warn" {
+		t.Errorf("expect warn, got %q %v", w.Body.String(), err)
+	}
+
+	w = authRequest(admin, http.MethodPut, "/admin/log/level", token, `{"level":"loud"}`)
+	if w.Code != http.StatusBadRequest || l.GetLogLevel() != "warn" {
+		t.Errorf("expect bad level rejected, got %d %s", w.Code, l.GetLogLevel())
+	}
+}
+
+func TestAdminDebugUids(t *testing.T) {
+	l := newTestAdminLogger(t)
+	admin, token := newTestAdmin(t, l)
+
+	if w := authRequest(admin, http.MethodPut, "/admin/log/debug-uids/10001?ttl=10m", token, ""); w.Code != http.StatusOK {
+		t.Fatalf("expect 200, got %d %s", w.Code, w.Body.String())
+	}
+	if w := authRequest(admin, http.MethodPut, "/admin/log/debug-uids/10002", token, ""); w.Code != http.StatusOK {
+		t.Fatalf("expect 200, got %d", w.Code)
+	}
+	if w := authRequest(admin, http.MethodPut, "/admin/log/debug-uids/abc", token, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expect 400, got %d", w.Code)
+	}
+
+	var list []debugUidBody
+	w := authRequest(admin, http.MethodGet, "/admin/log/debug-uids", token, "")
+	if err := json.Unmarshal(w.Body.Bytes(), &Envelope{Data: &list}); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Uid != 10001 || list[0].Until == nil || list[1].Until != nil {
+		t.Errorf("unexpected list %s", w.Body.String())
+	}
+
+	authRequest(admin, http.MethodDelete, "/admin/log/debug-uids/10001", token, "")
+	if uids := l.DebugUids(); len(uids) != 1 {
+		t.Errorf("expect 1 uid left, got %v", uids)
+	}
+}
+
+func TestAdminNeedsOperator(t *testing.T) {
+	l := newTestAdminLogger(t)
+	admin, _ := newTestAdmin(t, l)
+
+	if w := doRequest(admin, http.MethodGet, "/admin/log/level"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expect 401 without token, got %d", w.Code)
+	}
+}
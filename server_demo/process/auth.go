@@ -0,0 +1,333 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:49
+@Description: guest login and session token check
+*/
+
+package process
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/auth"
+	"github.com/Xbzzy/client_demo/server_demo/common/idgen"
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"github.com/Xbzzy/client_demo/server_demo/common/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	maxDeviceLen       = 64
+	maxGuests          = 100000 // devices remembered at once, more are refused until some expire
+	guestSweepInterval = time.Minute
+)
+
+// accountDevicePrefix marks the device of staff sessions, the account name follows.
+const accountDevicePrefix = "account:"
+
+// RequireLogin rejects requests to the route without a valid session with 401.
+func RequireLogin() RouteOption {
+	return func(r *Route) {
+		r.requireLogin = true
+	}
+}
+
+// RequireRoles limits the route to sessions having any of roles, others get 403.
+// Every call of such a route is audited.
+func RequireRoles(roles ...string) RouteOption {
+	return func(r *Route) {
+		r.requireLogin = true
+		r.roles = append(r.roles, roles...)
+	}
+}
+
+// bearerToken returns the token of "Authorization: Bearer <token>".
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		// browsers cannot set headers on a websocket, the token comes in the query then
+		if websocket.IsUpgrade(r) {
+			return r.URL.Query().Get("access_token")
+		}
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// Auth issues guest sessions and checks the token of every request.
+type Auth struct {
+	signer  *auth.Signer
+	revoked *auth.Revocation
+
+	mu         sync.Mutex
+	accounts   auth.Accounts
+	guests     map[string]guest // device -> guest, guests live in memory until we have storage
+	maxGuests  int
+	guestSweep time.Time
+}
+
+type guest struct {
+	uid  uint64
+	seen time.Time // last login or refresh, the entry is forgotten a token ttl after it
+}
+
+// NewAuth creates the auth of players, accounts are the staff that may log in with a password.
+func NewAuth(signer *auth.Signer, accounts auth.Accounts) *Auth {
+	return &Auth{
+		signer:     signer,
+		revoked:    auth.NewRevocation(signer.TTL()),
+		accounts:   accounts,
+		guests:     make(map[string]guest),
+		maxGuests:  maxGuests,
+		guestSweep: time.Now(),
+	}
+}
+
+// SetAccounts replaces the staff accounts, e.g. on config reload. Sessions of a removed account
+// keep working until they expire but can no longer be refreshed, a demoted one refreshes to its new roles.
+func (a *Auth) SetAccounts(accounts auth.Accounts) {
+	a.mu.Lock()
+	a.accounts = accounts
+	a.mu.Unlock()
+}
+
+// account returns the configured account a session was issued for, nil for guests and removed accounts.
+func (a *Auth) account(claims *auth.Claims) *auth.Account {
+	name, ok := strings.CutPrefix(claims.Device, accountDevicePrefix)
+	if !ok {
+		return nil
+	}
+	a.mu.Lock()
+	acc := a.accounts[name]
+	a.mu.Unlock()
+	// a guest may call its device "account:bob", the uid tells them apart
+	if acc == nil || acc.Uid != claims.Uid {
+		return nil
+	}
+	return acc
+}
+
+// Verify checks signature, expiry and revocation of token.
+func (a *Auth) Verify(token string) (*auth.Claims, error) {
+	claims, err := a.signer.Verify(token)
+	if err != nil {
+		return claims, err
+	}
+	if a.revoked.Revoked(claims) {
+		return claims, auth.ErrRevoked
+	}
+	return claims, nil
+}
+
+// Middleware sets the uid of a valid token on the logger and the session on the context.
+// A missing or bad token is a 401 on RequireLogin routes, elsewhere the request goes on anonymous
+// so a client holding an expired token can still log in again.
+func (a *Auth) Middleware(route *Route, next HttpHandler) HttpHandler {
+	if len(route.roles) > 0 {
+		next = AuditMiddleware(route, next)
+	}
+	return func(logger simplelog.LogI, params Params, w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			if route.requireLogin {
+				WriteError(logger, w, Unauthorized("login required"))
+				return
+			}
+			next(logger, params, w, r)
+			return
+		}
+
+		claims, err := a.Verify(token)
+		if err != nil {
+			fields := []zap.Field{zap.String("pattern", route.Pattern), zap.Error(err)}
+			if claims != nil {
+				fields = append(fields, zap.Uint64("tokenUid", claims.Uid), zap.Int64("tokenId", claims.Id))
+			}
+			if !route.requireLogin {
+				logger.DebugWF("auth token ignored", fields...)
+				next(logger, params, w, r)
+				return
+			}
+			logger.InfoWF("auth token rejected", fields...)
+
+			msg := "invalid token"
+			if errors.Is(err, auth.ErrExpired) {
+				msg = "token expired"
+			}
+			WriteError(logger, w, Unauthorized(msg))
+			return
+		}
+
+		logger.SetUid(claims.Uid)
+		if len(route.roles) > 0 && !claims.HasRole(route.roles...) {
+			Audit(logger, "auth permission denied", zap.String("method", r.Method), zap.String("pattern", route.Pattern),
+				zap.String("path", r.URL.Path), zap.Strings("roles", claims.Roles), zap.Strings("need", route.roles),
+				zap.String("remoteAddr", r.RemoteAddr))
+			WriteError(logger, w, Forbidden("permission denied"))
+			return
+		}
+		next(logger, params, w, r.WithContext(WithSession(r.Context(), claims)))
+	}
+}
+
+type guestLoginReq struct {
+	Device string `json:"device"`
+}
+
+func (req *guestLoginReq) Validate() error {
+	if req.Device == "" || len(req.Device) > maxDeviceLen {
+		return errors.New("device must be 1 to 64 bytes")
+	}
+	return nil
+}
+
+type sessionResp struct {
+	Token    string    `json:"token"`
+	Uid      uint64    `json:"uid,string"`
+	Roles    []string  `json:"roles,omitempty"`
+	ExpireAt time.Time `json:"expireAt"`
+}
+
+type accountLoginReq struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+func (req *accountLoginReq) Validate() error {
+	if req.Name == "" || req.Password == "" {
+		return errors.New("name and password are required")
+	}
+	return nil
+}
+
+type logoutReq struct {
+	All bool `json:"all"` // revoke the tokens of every device
+}
+
+// deviceHash stands for the device in logs, the device string is all a guest needs to log in as that uid.
+func deviceHash(device string) string {
+	sum := sha256.Sum256([]byte(device))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (a *Auth) issue(logger simplelog.LogI, uid uint64, device string, roles ...string) (*sessionResp, error) {
+	token, claims, err := a.signer.Issue(uid, device, roles...)
+	if err != nil {
+		return nil, err
+	}
+	logger.SetUid(uid)
+	logger.InfoWF("auth session issued", zap.Int64("tokenId", claims.Id), zap.String("deviceHash", deviceHash(device)),
+		zap.Strings("roles", roles), zap.Time("expireAt", claims.ExpireTime()))
+	return &sessionResp{Token: token, Uid: uid, Roles: roles, ExpireAt: claims.ExpireTime()}, nil
+}
+
+func (a *Auth) guestLogin(_ context.Context, logger simplelog.LogI, _ Params, req *guestLoginReq) (*sessionResp, error) {
+	now := time.Now()
+	a.mu.Lock()
+	if now.Sub(a.guestSweep) >= guestSweepInterval {
+		a.sweepGuests(now)
+	}
+	g, ok := a.guests[req.Device]
+	if !ok {
+		if len(a.guests) >= a.maxGuests {
+			a.mu.Unlock()
+			logger.WarnWF("auth guests full", zap.Int("guests", a.maxGuests))
+			return nil, NewError(http.StatusServiceUnavailable, "too many guests, try later")
+		}
+		g.uid = uint64(idgen.Next())
+	}
+	g.seen = now
+	a.guests[req.Device] = g
+	a.mu.Unlock()
+
+	if !ok {
+		logger.InfoWF("auth guest created", zap.Uint64("guestUid", g.uid), zap.String("deviceHash", deviceHash(req.Device)))
+	}
+	return a.issue(logger, g.uid, req.Device)
+}
+
+// touchGuest keeps the device of a refreshing guest remembered.
+func (a *Auth) touchGuest(claims *auth.Claims) {
+	a.mu.Lock()
+	if g, ok := a.guests[claims.Device]; ok && g.uid == claims.Uid {
+		g.seen = time.Now()
+		a.guests[claims.Device] = g
+	}
+	a.mu.Unlock()
+}
+
+// sweepGuests forgets the devices whose last token has expired by now, a.mu must be held.
+func (a *Auth) sweepGuests(now time.Time) {
+	for device, g := range a.guests {
+		if now.Sub(g.seen) >= a.signer.TTL() {
+			delete(a.guests, device)
+		}
+	}
+	a.guestSweep = now
+}
+
+// accountLogin lets staff from the config log in, their session carries the account roles.
+func (a *Auth) accountLogin(_ context.Context, logger simplelog.LogI, _ Params, req *accountLoginReq) (*sessionResp, error) {
+	a.mu.Lock()
+	accounts := a.accounts
+	a.mu.Unlock()
+	acc, ok := accounts.Check(req.Name, req.Password)
+	if !ok {
+		Audit(logger, "auth account login fail", zap.String("name", req.Name))
+		return nil, Unauthorized("bad name or password")
+	}
+	Audit(logger, "auth account login", zap.String("name", acc.Name), zap.Uint64("accountUid", acc.Uid),
+		zap.Strings("roles", acc.Roles))
+	return a.issue(logger, acc.Uid, accountDevicePrefix+acc.Name, acc.Roles...)
+}
+
+// refresh swaps a valid token for a new one, the old token stops working. Staff get the roles
+// their account has now, a removed account cannot refresh. Of concurrent refreshes with one
+// token only the first gets a new token.
+func (a *Auth) refresh(ctx context.Context, logger simplelog.LogI, _ Params, _ *struct{}) (*sessionResp, error) {
+	claims := SessionFrom(ctx)
+	if !a.revoked.RevokeIfNot(claims) {
+		logger.InfoWF("auth refresh of revoked token", zap.Int64("tokenId", claims.Id))
+		return nil, Unauthorized("invalid token")
+	}
+	var roles []string
+	// guests never have roles, every staff account has at least one
+	if len(claims.Roles) > 0 {
+		acc := a.account(claims)
+		if acc == nil {
+			Audit(logger, "auth refresh of removed account", zap.Int64("tokenId", claims.Id),
+				zap.Strings("roles", claims.Roles))
+			return nil, Unauthorized("account removed")
+		}
+		roles = acc.Roles
+	} else {
+		a.touchGuest(claims)
+	}
+	return a.issue(logger, claims.Uid, claims.Device, roles...)
+}
+
+func (a *Auth) logout(ctx context.Context, logger simplelog.LogI, _ Params, req *logoutReq) (struct{}, error) {
+	claims := SessionFrom(ctx)
+	if req.All {
+		a.revoked.RevokeUid(claims.Uid)
+	} else {
+		a.revoked.Revoke(claims)
+	}
+	logger.InfoWF("auth logout", zap.Int64("tokenId", claims.Id), zap.Bool("all", req.All))
+	return struct{}{}, nil
+}
+
+// Register mounts POST /auth/guest, /auth/account, /auth/refresh and /auth/logout on rt, which must use a.Middleware.
+func (a *Auth) Register(rt *Router) {
+	rt.POST("/auth/guest", JsonHandler(a.guestLogin))
+	rt.POST("/auth/account", JsonHandler(a.accountLogin))
+	rt.POST("/auth/refresh", JsonHandler(a.refresh), RequireLogin())
+	rt.POST("/auth/logout", JsonHandler(a.logout), RequireLogin())
+}
@@ -0,0 +1,234 @@
+package process
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/auth"
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+)
+
+func newAuthRouter(t *testing.T) (*Router, *Auth) {
+	signer, err := auth.NewSigner([]byte("0123456789abcdef"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accounts := make(auth.Accounts)
+	// password "secret"
+	if err = accounts.Add(auth.RoleShop, "bob:2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"); err != nil {
+		t.Fatal(err)
+	}
+	a := NewAuth(signer, accounts)
+	rt := newTestRouter()
+	rt.Use(a.Middleware)
+	a.Register(rt)
+	rt.GET("/me", func(logger simplelog.LogI, _ Params, w http.ResponseWriter, r *http.Request) {
+		WriteJson(logger, w, map[string]uint64{"uid": UidFrom(r.Context()), "logUid": logger.GetUid()})
+	}, RequireLogin())
+	rt.PUT("/shop/products/{id}", func(logger simplelog.LogI, _ Params, w http.ResponseWriter, _ *http.Request) {
+		WriteJson(logger, w, nil)
+	}, RequireRoles(auth.RoleShop, auth.RoleOperator))
+	return rt, a
+}
+
+func authRequest(h http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func guestLogin(t *testing.T, h http.Handler, device string) sessionResp {
+	w, _ := postJson(h, "/auth/guest", `{"device":"`+device+`"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("guest login: %d %s", w.Code, w.Body.String())
+	}
+	var env struct{ Data sessionResp }
+	if err := json.Unmarshal(w.Body.Bytes(), &env); err != nil {
+		t.Fatal(err)
+	}
+	return env.Data
+}
+
+func TestAuthGuestLogin(t *testing.T) {
+	rt, _ := newAuthRouter(t)
+
+	s := guestLogin(t, rt, "web-1")
+	if again := guestLogin(t, rt, "web-1"); again.Uid != s.Uid {
+		t.Errorf("expect same device same uid, got %d %d", s.Uid, again.Uid)
+	}
+
+	w := authRequest(rt, http.MethodGet, "/me", s.Token, "")
+	var env struct{ Data map[string]uint64 }
+	_ = json.Unmarshal(w.Body.Bytes(), &env)
+	if w.Code != http.StatusOK || env.Data["uid"] != s.Uid || env.Data["logUid"] != s.Uid {
+		t.Errorf("expect uid on context and logger, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w = authRequest(rt, http.MethodGet, "/me", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expect 401 without token, got %d", w.Code)
+	}
+	if w = authRequest(rt, http.MethodGet, "/me", s.Token+"x", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expect 401 with bad token, got %d", w.Code)
+	}
+	if w, _ = postJson(rt, "/auth/guest", `{"device":""}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expect 400 without device, got %d", w.Code)
+	}
+	// a stale token does not stop a new login
+	if w = authRequest(rt, http.MethodPost, "/auth/guest", s.Token+"x", `{"device":"web-1"}`); w.Code != http.StatusOK {
+		t.Errorf("expect login with a bad token, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAuthGuestLimit(t *testing.T) {
+	rt, a := newAuthRouter(t)
+	a.maxGuests = 2
+	first := guestLogin(t, rt, "web-1")
+	guestLogin(t, rt, "web-2")
+	if w, _ := postJson(rt, "/auth/guest", `{"device":"web-3"}`); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expect 503 once the guests are full, got %d", w.Code)
+	}
+	if again := guestLogin(t, rt, "web-1"); again.Uid != first.Uid {
+		t.Errorf("expect known device still logs in, got %d %d", first.Uid, again.Uid)
+	}
+
+	// entries not seen for a token ttl are swept and make room
+	a.mu.Lock()
+	for device, g := range a.guests {
+		g.seen = g.seen.Add(-2 * time.Hour)
+		a.guests[device] = g
+	}
+	a.guestSweep = time.Time{}
+	a.mu.Unlock()
+	guestLogin(t, rt, "web-3")
+}
+
+func TestAuthRefreshAndLogout(t *testing.T) {
+	rt, _ := newAuthRouter(t)
+	s := guestLogin(t, rt, "web-1")
+
+	w := authRequest(rt, http.MethodPost, "/auth/refresh", s.Token, "")
+	var env struct{ Data sessionResp }
+	_ = json.Unmarshal(w.Body.Bytes(), &env)
+	if w.Code != http.StatusOK || env.Data.Token == "" || env.Data.Uid != s.Uid {
+		t.Fatalf("refresh: %d %s", w.Code, w.Body.String())
+	}
+	if w = authRequest(rt, http.MethodGet, "/me", s.Token, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expect old token revoked after refresh, got %d", w.Code)
+	}
+
+	other := guestLogin(t, rt, "web-1")
+	w = authRequest(rt, http.MethodPost, "/auth/logout", env.Data.Token, `{"all":true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("logout: %d %s", w.Code, w.Body.String())
+	}
+	for _, token := range []string{env.Data.Token, other.Token} {
+		if w = authRequest(rt, http.MethodGet, "/me", token, ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("expect every device logged out, got %d", w.Code)
+		}
+	}
+}
+
+func TestAuthRefreshOnce(t *testing.T) {
+	rt, _ := newAuthRouter(t)
+	s := guestLogin(t, rt, "web-1")
+
+	codes := make(chan int, 8)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(codes); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- authRequest(rt, http.MethodPost, "/auth/refresh", s.Token, "").Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	ok := 0
+	for code := range codes {
+		switch code {
+		case http.StatusOK:
+			ok++
+		case http.StatusUnauthorized:
+		default:
+			t.Errorf("unexpected refresh status %d", code)
+		}
+	}
+	if ok != 1 {
+		t.Errorf("expect one refresh to win, got %d", ok)
+	}
+}
+
+func TestAuthRoles(t *testing.T) {
+	rt, _ := newAuthRouter(t)
+
+	guest := guestLogin(t, rt, "web-1")
+	w := authRequest(rt, http.MethodPut, "/shop/products/1", guest.Token, "")
+	var env Envelope
+	_ = json.Unmarshal(w.Body.Bytes(), &env)
+	if w.Code != http.StatusForbidden || env.Code != http.StatusForbidden || env.Msg != "permission denied" {
+		t.Errorf("expect 403 envelope for guest, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w, _ = postJson(rt, "/auth/account", `{"name":"bob","password":"wrong"}`); w.Code != http.StatusUnauthorized {
+		t.Errorf("expect 401 with bad password, got %d", w.Code)
+	}
+	w, _ = postJson(rt, "/auth/account", `{"name":"bob","password":"secret"}`)
+	var login struct{ Data sessionResp }
+	_ = json.Unmarshal(w.Body.Bytes(), &login)
+	if w.Code != http.StatusOK || login.Data.Uid != auth.AccountUid("bob") || len(login.Data.Roles) != 1 {
+		t.Fatalf("account login: %d %s", w.Code, w.Body.String())
+	}
+	if w = authRequest(rt, http.MethodPut, "/shop/products/1", login.Data.Token, ""); w.Code != http.StatusOK {
+		t.Errorf("expect shop staff allowed, got %d %s", w.Code, w.Body.String())
+	}
+
+	// roles survive a refresh
+	w = authRequest(rt, http.MethodPost, "/auth/refresh", login.Data.Token, "")
+	_ = json.Unmarshal(w.Body.Bytes(), &login)
+	if w = authRequest(rt, http.MethodPut, "/shop/products/1", login.Data.Token, ""); w.Code != http.StatusOK {
+		t.Errorf("expect refreshed token keeps roles, got %d", w.Code)
+	}
+}
+
+func TestAuthRefreshAccountChanged(t *testing.T) {
+	rt, a := newAuthRouter(t)
+	w, _ := postJson(rt, "/auth/account", `{"name":"bob","password":"secret"}`)
+	var login struct{ Data sessionResp }
+	_ = json.Unmarshal(w.Body.Bytes(), &login)
+
+	// bob is moved from shop to operator in the config
+	accounts := make(auth.Accounts)
+	if err := accounts.Add(auth.RoleOperator, "bob:2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"); err != nil {
+		t.Fatal(err)
+	}
+	a.SetAccounts(accounts)
+	w = authRequest(rt, http.MethodPost, "/auth/refresh", login.Data.Token, "")
+	_ = json.Unmarshal(w.Body.Bytes(), &login)
+	if w.Code != http.StatusOK || len(login.Data.Roles) != 1 || login.Data.Roles[0] != auth.RoleOperator {
+		t.Fatalf("expect the current roles after refresh, got %d %s", w.Code, w.Body.String())
+	}
+
+	// and then removed
+	a.SetAccounts(make(auth.Accounts))
+	if w = authRequest(rt, http.MethodPost, "/auth/refresh", login.Data.Token, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expect removed account cannot refresh, got %d", w.Code)
+	}
+	if w = authRequest(rt, http.MethodPut, "/shop/products/1", login.Data.Token, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expect the refused token revoked, got %d", w.Code)
+	}
+
+	// a guest naming its device after the account still refreshes
+	guest := guestLogin(t, rt, "account:bob")
+	if w = authRequest(rt, http.MethodPost, "/auth/refresh", guest.Token, ""); w.Code != http.StatusOK {
+		t.Errorf("expect guest refresh, got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,233 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:47
+@Description: gzip/deflate response compression staged in pooled io buffers
+*/
+
+package process
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/buffer"
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"go.uber.org/zap"
+)
+
+const DefaultCompressMinSize = 1 << 10
+
+var (
+	gzipPool = sync.Pool{New: func() interface{} { return gzip.NewWriter(io.Discard) }}
+	zlibPool = sync.Pool{New: func() interface{} { return zlib.NewWriter(io.Discard) }}
+)
+
+// acceptEncoding reports whether Accept-Encoding allows enc, "*" counts and q=0 refuses.
+func acceptEncoding(r *http.Request, enc string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, q, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.TrimSpace(name)
+		if name != enc && name != "*" {
+			continue
+		}
+		if v, ok := strings.CutPrefix(strings.TrimSpace(q), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil && f == 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// negotiateEncoding picks gzip before deflate, "" means identity.
+func negotiateEncoding(r *http.Request) string {
+	switch {
+	case acceptEncoding(r, "gzip"):
+		return "gzip"
+	case acceptEncoding(r, "deflate"):
+		return "deflate"
+	}
+	return ""
+}
+
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressWriter stages the response in an IoBuffer until minSize is reached, smaller responses
+// go out as they are, larger ones are compressed as a stream.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	staged    buffer.IoBuffer
+	status    int
+	statusSet bool // WriteHeader was called, close must send it even without a body
+	started   bool
+	zw        compressor // nil while staging or when sent as identity
+}
+
+func newCompressWriter(w http.ResponseWriter, encoding string, minSize int) *compressWriter {
+	return &compressWriter{
+		ResponseWriter: w,
+		encoding:       encoding,
+		minSize:        minSize,
+		staged:         buffer.GetIoBuffer(minSize),
+		status:         http.StatusOK,
+	}
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.started || status < http.StatusOK {
+		if !w.started {
+			w.ResponseWriter.WriteHeader(status)
+		}
+		return
+	}
+	w.status = status
+	w.statusSet = true
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.started {
+		if w.zw != nil {
+			return w.zw.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+
+	if _, err := w.staged.Write(p); err != nil {
+		return 0, err
+	}
+	if w.staged.Len() >= w.minSize {
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// compressible checks the response is worth compressing once the headers are final.
+func (w *compressWriter) compressible() bool {
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	if w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		return false
+	}
+	if h.Get("Content-Type") == "" {
+		h.Set("Content-Type", http.DetectContentType(w.staged.Bytes()))
+	}
+	return compressible(h.Get("Content-Type"))
+}
+
+// start sends the header and the staged bytes, compressed when compress is set and the response allows it.
+func (w *compressWriter) start(compress bool) error {
+	w.started = true
+	h := w.Header()
+	h.Add("Vary", "Accept-Encoding")
+
+	if compress && w.compressible() {
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		if w.encoding == "gzip" {
+			w.zw = gzipPool.Get().(*gzip.Writer)
+		} else {
+			w.zw = zlibPool.Get().(*zlib.Writer)
+		}
+		w.zw.Reset(w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.staged.Len() == 0 {
+		return nil
+	}
+	if w.zw != nil {
+		_, err := w.staged.WriteTo(w.zw)
+		return err
+	}
+	_, err := w.staged.WriteTo(w.ResponseWriter)
+	return err
+}
+
+// Flush sends what is staged compressed, a streaming handler wants it now whatever the size.
+func (w *compressWriter) Flush() {
+	if !w.started {
+		_ = w.start(true)
+	}
+	if w.zw != nil {
+		_ = w.zw.Flush()
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// close finishes the response after the handler returned normally. When the handler wrote nothing
+// the response is left to the outer middlewares, e.g. the 503 of a timed out route.
+func (w *compressWriter) close() error {
+	if !w.started {
+		if !w.statusSet && w.staged.Len() == 0 {
+			return nil
+		}
+		if err := w.start(false); err != nil {
+			return err
+		}
+	}
+	if w.zw == nil {
+		return nil
+	}
+	err := w.zw.Close()
+	if w.encoding == "gzip" {
+		gzipPool.Put(w.zw)
+	} else {
+		zlibPool.Put(w.zw)
+	}
+	w.zw = nil
+	return err
+}
+
+// release gives the staging buffer back, it also runs when the handler panics.
+func (w *compressWriter) release() {
+	if w.staged != nil {
+		_ = buffer.PutIoBuffer(w.staged)
+		w.staged = nil
+	}
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// CompressMiddleware compresses responses of at least minSize bytes with gzip or deflate
+// as the client accepts, 0 uses DefaultCompressMinSize.
+func CompressMiddleware(minSize int) Middleware {
+	if minSize <= 0 {
+		minSize = DefaultCompressMinSize
+	}
+	return func(route *Route, next HttpHandler) HttpHandler {
+		return func(logger simplelog.LogI, params Params, w http.ResponseWriter, r *http.Request) {
+			encoding := negotiateEncoding(r)
+			if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+				next(logger, params, w, r)
+				return
+			}
+
+			cw := newCompressWriter(w, encoding, minSize)
+			defer cw.release()
+			next(logger, params, cw, r)
+			if err := cw.close(); err != nil {
+				logger.InfoWF("http compress fail", zap.String("pattern", route.Pattern),
+					zap.String("encoding", encoding), zap.Error(err))
+			}
+		}
+	}
+}
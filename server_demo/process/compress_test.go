@@ -0,0 +1,104 @@
+package process
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+)
+
+func newCompressRouter() *Router {
+	rt := newTestRouter()
+	rt.Use(CompressMiddleware(64))
+	rt.GET("/catalog", func(logger simplelog.LogI, _ Params, w http.ResponseWriter, _ *http.Request) {
+		items := make([]string, 50)
+		for i := range items {
+			items[i] = "sword"
+		}
+		WriteJson(logger, w, items)
+	})
+	rt.GET("/small", func(logger simplelog.LogI, _ Params, w http.ResponseWriter, _ *http.Request) {
+		WriteJson(logger, w, "ok")
+	})
+	return rt
+}
+
+func compressRequest(h http.Handler, path, encoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Accept-Encoding", encoding)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestCompressNegotiation(t *testing.T) {
+	rt := newCompressRouter()
+
+	w := compressRequest(rt, "/catalog", "gzip, deflate")
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expect gzip, got %v", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(zr)
+	if !strings.Contains(string(body), `"sword"`) {
+		t.Errorf("unexpected body %s", body)
+	}
+
+	w = compressRequest(rt, "/catalog", "gzip;q=0, deflate")
+	if w.Header().Get("Content-Encoding") != "deflate" {
+		t.Fatalf("expect deflate, got %v", w.Header())
+	}
+	fr, err := zlib.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ = io.ReadAll(fr); !strings.Contains(string(body), `"sword"`) {
+		t.Errorf("unexpected body %s", body)
+	}
+
+	w = compressRequest(rt, "/catalog", "br")
+	if w.Header().Get("Content-Encoding") != "" || !strings.Contains(w.Body.String(), `"sword"`) {
+		t.Errorf("expect identity, got %v", w.Header())
+	}
+}
+
+func TestCompressBelowMinSize(t *testing.T) {
+	rt := newCompressRouter()
+
+	w := compressRequest(rt, "/small", "gzip")
+	if w.Header().Get("Content-Encoding") != "" || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("expect identity with Vary, got %v", w.Header())
+	}
+	if !strings.Contains(w.Body.String(), `"data":"ok"`) {
+		t.Errorf("unexpected body %s", w.Body.String())
+	}
+}
+
+func TestCompressTimeout(t *testing.T) {
+	rt := newCompressRouter()
+	rt.GET("/wait", func(_ simplelog.LogI, _ Params, _ http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}, WithTimeout(20*time.Millisecond))
+	rt.GET("/created", func(_ simplelog.LogI, _ Params, w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	// the handler wrote nothing, the timeout envelope of LimitMiddleware goes out
+	w := compressRequest(rt, "/wait", "gzip")
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "handler timeout") {
+		t.Errorf("expect 503 timeout, got %d %q", w.Code, w.Body.String())
+	}
+	// a status without body is still sent
+	if w = compressRequest(rt, "/created", "gzip"); w.Code != http.StatusCreated {
+		t.Errorf("expect 201, got %d", w.Code)
+	}
+}
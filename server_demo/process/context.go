@@ -0,0 +1,50 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:41
+@Description: values carried on the request context
+*/
+
+package process
+
+import (
+	"context"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/auth"
+)
+
+type ctxKey int
+
+const (
+	requestIdKey ctxKey = iota
+	sessionKey
+)
+
+// WithRequestId returns ctx carrying the request id.
+func WithRequestId(ctx context.Context, id int64) context.Context {
+	return context.WithValue(ctx, requestIdKey, id)
+}
+
+// RequestIdFrom returns the request id set by RequestIdMiddleware.
+func RequestIdFrom(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(requestIdKey).(int64)
+	return id, ok
+}
+
+// WithSession returns ctx carrying the verified token claims.
+func WithSession(ctx context.Context, claims *auth.Claims) context.Context {
+	return context.WithValue(ctx, sessionKey, claims)
+}
+
+// SessionFrom returns the claims set by Auth.Middleware, nil for anonymous requests.
+func SessionFrom(ctx context.Context) *auth.Claims {
+	claims, _ := ctx.Value(sessionKey).(*auth.Claims)
+	return claims
+}
+
+// UidFrom returns the uid of the logged in player, 0 for anonymous requests.
+func UidFrom(ctx context.Context) uint64 {
+	if claims := SessionFrom(ctx); claims != nil {
+		return claims.Uid
+	}
+	return 0
+}
@@ -0,0 +1,96 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:44
+@Description: cors for the react dev servers
+*/
+
+package process
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"github.com/Xbzzy/client_demo/server_demo/common/util"
+	"go.uber.org/zap"
+)
+
+type CorsConfig struct {
+	AllowOrigins     []string // exact origins like http://localhost:3000, "*" allows any
+	AllowMethods     []string // empty uses GET, POST, PUT, DELETE
+	AllowHeaders     []string // empty uses Content-Type, Authorization, X-Request-Id
+	ExposeHeaders    []string // empty uses X-Request-Id
+	AllowCredentials bool
+	MaxAge           time.Duration // how long the browser caches a preflight
+}
+
+func (c *CorsConfig) allowOrigin(origin string) bool {
+	return util.InStrings(c.AllowOrigins, "*") || util.InStrings(c.AllowOrigins, origin)
+}
+
+func orDefault(list []string, def ...string) []string {
+	if len(list) == 0 {
+		return def
+	}
+	return list
+}
+
+// CorsMiddleware adds the cors headers for allowed origins, preflights are answered
+// with 204 without calling the handler.
+func CorsMiddleware(cfg CorsConfig) Middleware {
+	methods := strings.Join(orDefault(cfg.AllowMethods, http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete), ", ")
+	headers := strings.Join(orDefault(cfg.AllowHeaders, "Content-Type", "Authorization", RequestIdHeader), ", ")
+	expose := strings.Join(orDefault(cfg.ExposeHeaders, RequestIdHeader), ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	return func(route *Route, next HttpHandler) HttpHandler {
+		return func(logger simplelog.LogI, params Params, w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next(logger, params, w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			allowed := cfg.allowOrigin(origin)
+
+			if allowed {
+				// credentials need the exact origin, never "*"
+				if util.InStrings(cfg.AllowOrigins, "*") && !cfg.AllowCredentials {
+					h.Set("Access-Control-Allow-Origin", "*")
+				} else {
+					h.Set("Access-Control-Allow-Origin", origin)
+				}
+				if cfg.AllowCredentials {
+					h.Set("Access-Control-Allow-Credentials", "true")
+				}
+			}
+
+			if !preflight {
+				if allowed {
+					h.Set("Access-Control-Expose-Headers", expose)
+				}
+				next(logger, params, w, r)
+				return
+			}
+
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			if allowed {
+				h.Set("Access-Control-Allow-Methods", methods)
+				h.Set("Access-Control-Allow-Headers", headers)
+				if cfg.MaxAge > 0 {
+					h.Set("Access-Control-Max-Age", maxAge)
+				}
+			}
+			logger.DebugWF("cors preflight", zap.String("pattern", route.Pattern), zap.String("origin", origin),
+				zap.Bool("allowed", allowed), zap.String("requestMethod", r.Header.Get("Access-Control-Request-Method")),
+				zap.String("requestHeaders", r.Header.Get("Access-Control-Request-Headers")))
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
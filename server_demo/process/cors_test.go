@@ -0,0 +1,77 @@
+package process
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+)
+
+func newCorsRouter(cfg CorsConfig, called *int) *Router {
+	rt := newTestRouter()
+	rt.Use(CorsMiddleware(cfg))
+	rt.POST("/room/{id}/move", func(simplelog.LogI, Params, http.ResponseWriter, *http.Request) {
+		*called++
+	})
+	return rt
+}
+
+func corsRequest(h http.Handler, method, origin string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/room/1/move", nil)
+	req.Header.Set("Origin", origin)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestCorsPreflight(t *testing.T) {
+	called := 0
+	rt := newCorsRouter(CorsConfig{AllowOrigins: []string{"http://localhost:3000"}, MaxAge: time.Minute}, &called)
+
+	w := corsRequest(rt, http.MethodOptions, "http://localhost:3000", map[string]string{
+		"Access-Control-Request-Method":  http.MethodPost,
+		"Access-Control-Request-Headers": "content-type",
+	})
+	h := w.Header()
+	if w.Code != http.StatusNoContent || called != 0 {
+		t.Fatalf("expect 204 without handler, got %d called %d", w.Code, called)
+	}
+	if h.Get("Access-Control-Allow-Origin") != "http://localhost:3000" || h.Get("Access-Control-Allow-Methods") == "" ||
+		h.Get("Access-Control-Max-Age") != "60" {
+		t.Errorf("unexpected preflight headers %v", h)
+	}
+
+	// unknown origin gets no cors headers, the browser then blocks the call
+	w = corsRequest(rt, http.MethodOptions, "http://evil.com", map[string]string{"Access-Control-Request-Method": http.MethodPost})
+	if w.Header().Get("Access-Control-Allow-Origin") != "" || called != 0 {
+		t.Errorf("expect no allow origin, got %v", w.Header())
+	}
+}
+
+func TestCorsSimpleRequest(t *testing.T) {
+	called := 0
+	rt := newCorsRouter(CorsConfig{AllowOrigins: []string{"*"}, AllowCredentials: true}, &called)
+
+	w := corsRequest(rt, http.MethodPost, "http://localhost:3001", nil)
+	h := w.Header()
+	if called != 1 || h.Get("Access-Control-Allow-Origin") != "http://localhost:3001" ||
+		h.Get("Access-Control-Allow-Credentials") != "true" || h.Get("Access-Control-Expose-Headers") != RequestIdHeader {
+		t.Errorf("unexpected headers %v called %d", h, called)
+	}
+}
+
+func TestAutoOptionsWithoutCors(t *testing.T) {
+	rt := newTestRouter()
+	rt.POST("/room/{id}/move", func(simplelog.LogI, Params, http.ResponseWriter, *http.Request) {})
+	rt.PUT("/room/{id}/move", func(simplelog.LogI, Params, http.ResponseWriter, *http.Request) {})
+
+	w := doRequest(rt, http.MethodOptions, "/room/1/move")
+	if w.Code != http.StatusNoContent || w.Header().Get("Allow") != "OPTIONS, POST, PUT" {
+		t.Errorf("expect 204 with Allow, got %d %q", w.Code, w.Header().Get("Allow"))
+	}
+}
@@ -0,0 +1,151 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:54
+@Description: pprof and runtime diagnostics for operators, admin listener only
+*/
+
+package process
+
+import (
+	"context"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/buffer"
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"go.uber.org/zap"
+)
+
+// importing net/http/pprof also registers it on http.DefaultServeMux, which main never serves
+
+// stdHandler adapts a plain net/http handler to a route.
+func stdHandler(h http.HandlerFunc) HttpHandler {
+	return func(_ simplelog.LogI, _ Params, w http.ResponseWriter, r *http.Request) {
+		h(w, r)
+	}
+}
+
+type memStatsBody struct {
+	Goroutines int `json:"goroutines"`
+
+	HeapAlloc    uint64 `json:"heapAlloc"`
+	HeapInuse    uint64 `json:"heapInuse"`
+	HeapIdle     uint64 `json:"heapIdle"`
+	HeapReleased uint64 `json:"heapReleased"`
+	HeapObjects  uint64 `json:"heapObjects"`
+	Sys          uint64 `json:"sys"`
+	TotalAlloc   uint64 `json:"totalAlloc"`
+	Mallocs      uint64 `json:"mallocs"`
+	Frees        uint64 `json:"frees"`
+
+	NumGC         uint32        `json:"numGC"`
+	NextGC        uint64        `json:"nextGC"`
+	LastGC        time.Time     `json:"lastGC"`
+	LastPause     time.Duration `json:"lastPauseNs"`
+	PauseTotal    time.Duration `json:"pauseTotalNs"`
+	GCCPUFraction float64       `json:"gcCpuFraction"`
+
+	IoBufferPool buffer.PoolStats `json:"ioBufferPool"`
+	BytesPool    buffer.PoolStats `json:"bytesPool"`
+}
+
+func readMemStats() *memStatsBody {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	body := &memStatsBody{
+		Goroutines:    runtime.NumGoroutine(),
+		HeapAlloc:     m.HeapAlloc,
+		HeapInuse:     m.HeapInuse,
+		HeapIdle:      m.HeapIdle,
+		HeapReleased:  m.HeapReleased,
+		HeapObjects:   m.HeapObjects,
+		Sys:           m.Sys,
+		TotalAlloc:    m.TotalAlloc,
+		Mallocs:       m.Mallocs,
+		Frees:         m.Frees,
+		NumGC:         m.NumGC,
+		NextGC:        m.NextGC,
+		PauseTotal:    time.Duration(m.PauseTotalNs),
+		GCCPUFraction: m.GCCPUFraction,
+		IoBufferPool:  buffer.IoBufferPoolStats(),
+		BytesPool:     buffer.BytesPoolStats(),
+	}
+	if m.NumGC > 0 {
+		body.LastGC = time.Unix(0, int64(m.LastGC))
+		body.LastPause = time.Duration(m.PauseNs[(m.NumGC+255)%256])
+	}
+	return body
+}
+
+type buildInfoBody struct {
+	GoVersion string            `json:"goVersion"`
+	Path      string            `json:"path"`
+	Version   string            `json:"version"`
+	Settings  map[string]string `json:"settings"` // vcs.revision, vcs.time, vcs.modified, GOOS ...
+	Deps      map[string]string `json:"deps"`
+}
+
+// registerDebug mounts pprof under /debug/pprof/ so `go tool pprof` paths stay the usual ones,
+// the tool cannot send a token so fetch with curl -H "Authorization: Bearer ..." and open the file.
+func registerDebug(router *Router, operator RouteOption) {
+	long := WithSlowThreshold(NoSlowThreshold)
+
+	router.GET("/debug/pprof/{name...}", stdHandler(pprof.Index), operator)
+	router.GET("/debug/pprof/cmdline", stdHandler(pprof.Cmdline), operator)
+	router.GET("/debug/pprof/profile", stdHandler(pprof.Profile), operator, long)
+	router.GET("/debug/pprof/symbol", stdHandler(pprof.Symbol), operator)
+	router.POST("/debug/pprof/symbol", stdHandler(pprof.Symbol), operator)
+	router.GET("/debug/pprof/trace", stdHandler(pprof.Trace), operator, long)
+
+	router.GET("/admin/debug/goroutines", func(_ simplelog.LogI, _ Params, w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		buf := make([]byte, 1<<20)
+		for {
+			n := runtime.Stack(buf, true)
+			if n < len(buf) {
+				_, _ = w.Write(buf[:n])
+				return
+			}
+			buf = make([]byte, 2*len(buf))
+		}
+	}, operator, long)
+
+	router.GET("/admin/debug/memstats", JsonHandler(func(_ context.Context, _ simplelog.LogI, _ Params, _ *struct{}) (*memStatsBody, error) {
+		return readMemStats(), nil
+	}), operator)
+
+	// POST /admin/debug/gc runs a gc and returns memory to the os, to tell garbage from leaks
+	router.POST("/admin/debug/gc", JsonHandler(func(_ context.Context, logger simplelog.LogI, _ Params, _ *struct{}) (*memStatsBody, error) {
+		before := readMemStats()
+		start := time.Now()
+		debug.FreeOSMemory()
+		after := readMemStats()
+		logger.InfoWF("admin force gc", zap.Duration("cost", time.Since(start)),
+			zap.Uint64("heapAllocBefore", before.HeapAlloc), zap.Uint64("heapAllocAfter", after.HeapAlloc))
+		return after, nil
+	}), operator)
+
+	router.GET("/admin/debug/buildinfo", JsonHandler(func(_ context.Context, _ simplelog.LogI, _ Params, _ *struct{}) (*buildInfoBody, error) {
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return nil, NotFound("binary built without module support")
+		}
+		body := &buildInfoBody{
+			GoVersion: info.GoVersion,
+			Path:      info.Path,
+			Version:   info.Main.Version,
+			Settings:  make(map[string]string, len(info.Settings)),
+			Deps:      make(map[string]string, len(info.Deps)),
+		}
+		for _, s := range info.Settings {
+			body.Settings[s.Key] = s.Value
+		}
+		for _, d := range info.Deps {
+			body.Deps[d.Path] = d.Version
+		}
+		return body, nil
+	}), operator)
+}
@@ -0,0 +1,47 @@
+package process
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAdminDebug(t *testing.T) {
+	admin, token := newTestAdmin(t, newTestAdminLogger(t))
+
+	w := authRequest(admin, http.MethodGet, "/debug/pprof/", token, "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "goroutine") {
+		t.Errorf("expect pprof index, got %d", w.Code)
+	}
+	w = authRequest(admin, http.MethodGet, "/debug/pprof/heap?debug=1", token, "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "heap profile") {
+		t.Errorf("expect heap profile, got %d", w.Code)
+	}
+	w = authRequest(admin, http.MethodGet, "/admin/debug/goroutines", token, "")
+	if !strings.Contains(w.Body.String(), "goroutine ") {
+		t.Errorf("expect goroutine dump, got %q", w.Body.String())
+	}
+
+	var stats memStatsBody
+	w = authRequest(admin, http.MethodGet, "/admin/debug/memstats", token, "")
+	if err := json.Unmarshal(w.Body.Bytes(), &Envelope{Data: &stats}); err != nil || stats.Goroutines == 0 || stats.HeapAlloc == 0 {
+		t.Errorf("unexpected memstats %s", w.Body.String())
+	}
+	if w = authRequest(admin, http.MethodPost, "/admin/debug/gc", token, ""); w.Code != http.StatusOK {
+		t.Errorf("expect gc ok, got %d", w.Code)
+	}
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap", "/admin/debug/memstats", "/admin/debug/buildinfo"} {
+		if w = doRequest(admin, http.MethodGet, path); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expect 401 without token, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestPublicRouterHasNoPprof(t *testing.T) {
+	// net/http/pprof registers itself on http.DefaultServeMux, the public router must not fall back to it
+	if w := doRequest(DefaultRouter, http.MethodGet, "/debug/pprof/"); w.Code != http.StatusNotFound {
+		t.Errorf("expect 404 on the public router, got %d", w.Code)
+	}
+}
@@ -0,0 +1,241 @@
+/*
+@Author: agent
+@Date: 2026/10/16 21:13
+@Description: message id dispatcher shared by http, websocket and the tcp gateway
+*/
+
+package process
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/buffer"
+	"github.com/Xbzzy/client_demo/server_demo/common/idgen"
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"github.com/Xbzzy/client_demo/server_demo/common/websocket"
+	"go.uber.org/zap"
+)
+
+// wsReply is the WsMessage type answering a MsgRequest.
+const wsReply = "reply"
+
+// MsgFunc is the business function of a message, the same one serves every transport.
+// ctx carries the session like on http, so UidFrom works.
+type MsgFunc[Req any, Resp any] func(ctx context.Context, logger simplelog.LogI, req *Req) (Resp, error)
+
+type msgRoute struct {
+	Id   uint32
+	Name string
+	call func(ctx context.Context, logger simplelog.LogI, payload []byte) (interface{}, error)
+}
+
+// Dispatcher routes json payloads by message id. Transports only turn their frames into
+// (msgId, payload) and the resulting Envelope back into frames.
+type Dispatcher struct {
+	mu     sync.RWMutex
+	byId   map[uint32]*msgRoute
+	byName map[string]*msgRoute
+}
+
+// DefaultDispatcher is served on /msg/{id}, by DefaultHub and by the gateway mounted in main.
+var DefaultDispatcher = NewDispatcher()
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		byId:   make(map[uint32]*msgRoute),
+		byName: make(map[string]*msgRoute),
+	}
+}
+
+// HandleMsg registers fn for msgId, the payload is decoded into Req and validated like JsonHandler.
+// A taken id or name panics like a duplicate route.
+func HandleMsg[Req any, Resp any](d *Dispatcher, msgId uint32, name string, fn MsgFunc[Req, Resp]) {
+	if fn == nil || name == "" {
+		panic("process: message " + strconv.FormatUint(uint64(msgId), 10) + " needs a name and a handler")
+	}
+	route := &msgRoute{Id: msgId, Name: name}
+	route.call = func(ctx context.Context, logger simplelog.LogI, payload []byte) (interface{}, error) {
+		req := new(Req)
+		if len(bytes.TrimSpace(payload)) > 0 {
+			if err := json.Unmarshal(payload, req); err != nil {
+				return nil, BadRequest("bad message body: " + err.Error())
+			}
+		}
+		if v, ok := interface{}(req).(Validator); ok {
+			if err := v.Validate(); err != nil {
+				return nil, BadRequest(err.Error())
+			}
+		}
+		return fn(ctx, logger, req)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.byId[msgId]; ok {
+		panic("process: duplicate message id " + strconv.FormatUint(uint64(msgId), 10))
+	}
+	if _, ok := d.byName[name]; ok {
+		panic("process: duplicate message name " + name)
+	}
+	d.byId[msgId] = route
+	d.byName[name] = route
+}
+
+// Ids returns the registered message ids in order.
+func (d *Dispatcher) Ids() []uint32 {
+	d.mu.RLock()
+	ids := make([]uint32, 0, len(d.byId))
+	for id := range d.byId {
+		ids = append(ids, id)
+	}
+	d.mu.RUnlock()
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// lookup finds a message by id or by name, http clients may use either.
+func (d *Dispatcher) lookup(key string) *msgRoute {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if id, err := strconv.ParseUint(key, 10, 32); err == nil {
+		return d.byId[uint32(id)]
+	}
+	return d.byName[key]
+}
+
+// Dispatch runs msgId for a connection whose logger is l. Like the router does per request, the
+// handler gets a clone of l with a new log id and the uid of ctx, the connection id stays in the start log.
+// A panic is logged with the stack and answered as a 500.
+func (d *Dispatcher) Dispatch(ctx context.Context, l simplelog.LogI, msgId uint32, payload []byte) Envelope {
+	logger := l.Clone()
+	logger.SetLogId(idgen.Next())
+	logger.SetUid(UidFrom(ctx))
+
+	d.mu.RLock()
+	route := d.byId[msgId]
+	d.mu.RUnlock()
+	if route == nil {
+		logger.InfoWF("msg unknown", zap.Uint32("msgId", msgId), zap.Int64("sessionId", l.GetLogId()))
+		msgRequests.With(unmatchedPattern, strconv.Itoa(http.StatusNotFound)).Inc()
+		return errorEnvelope(logger, NotFound("unknown message"))
+	}
+
+	logger.DebugWF("start msg", zap.Uint32("msgId", msgId), zap.String("name", route.Name),
+		zap.Int64("sessionId", l.GetLogId()), zap.Int("len", len(payload)))
+	resp, err := d.run(ctx, logger, route, payload)
+	if err != nil {
+		return errorEnvelope(logger, err)
+	}
+	return Envelope{Code: CodeOk, Msg: "ok", LogId: logger.GetLogId(), Data: resp}
+}
+
+// run calls the handler of route with recovery and counts the result.
+func (d *Dispatcher) run(ctx context.Context, logger simplelog.LogI, route *msgRoute, payload []byte) (resp interface{}, err error) {
+	start := time.Now()
+	defer func() {
+		if rec := recover(); rec != nil {
+			msgPanics.With(route.Name).Inc()
+			logger.ErrorWF("msg panic", zap.Uint32("msgId", route.Id), zap.String("name", route.Name),
+				zap.String("panic", fmt.Sprint(rec)), zap.ByteString("stack", debug.Stack()))
+			resp, err = nil, NewError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		}
+
+		code := CodeOk
+		if err != nil {
+			code = http.StatusInternalServerError
+			var e *Error
+			if errors.As(err, &e) {
+				code = e.Code
+			}
+		}
+		msgRequests.With(route.Name, strconv.Itoa(code)).Inc()
+		logger.DebugWF("end msg", zap.String("name", route.Name), zap.Int("code", code),
+			zap.Duration("cost", time.Since(start)))
+	}()
+
+	return route.call(simplelog.NewContext(ctx, logger), logger, payload)
+}
+
+// errorEnvelope is WriteError without the http status, errors other than *Error become a 500 and are logged.
+func errorEnvelope(logger simplelog.LogI, err error) Envelope {
+	var e *Error
+	if !errors.As(err, &e) {
+		logger.WarnWF("msg handler error", zap.Error(err))
+		e = NewError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+	return Envelope{Code: e.Code, Msg: e.Msg, LogId: logger.GetLogId()}
+}
+
+// HttpHandler serves POST /msg/{id}, id is the message id or name and the body its json payload.
+// The router already cloned the logger and set the request id, so the handler runs on it.
+func (d *Dispatcher) HttpHandler() HttpHandler {
+	return func(logger simplelog.LogI, params Params, w http.ResponseWriter, r *http.Request) {
+		route := d.lookup(params.Get("id"))
+		if route == nil {
+			WriteError(logger, w, NotFound("unknown message"))
+			return
+		}
+
+		var payload []byte
+		if r.Body != nil {
+			var err error
+			if payload, err = io.ReadAll(r.Body); err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					WriteError(logger, w, NewError(http.StatusRequestEntityTooLarge, "request body too large"))
+					return
+				}
+				WriteError(logger, w, BadRequest("bad request body: "+err.Error()))
+				return
+			}
+		}
+
+		resp, err := d.run(r.Context(), logger, route, payload)
+		if err != nil {
+			WriteError(logger, w, err)
+			return
+		}
+		WriteJson(logger, w, resp)
+	}
+}
+
+// MsgRequest is a dispatcher call in a websocket text frame, Seq comes back in the reply so
+// clients can match them.
+type MsgRequest struct {
+	MsgId uint32          `json:"msgId"`
+	Seq   uint64          `json:"seq,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// MsgReply answers a MsgRequest as the data of a WsMessage of type "reply".
+type MsgReply struct {
+	MsgId uint32 `json:"msgId"`
+	Seq   uint64 `json:"seq,omitempty"`
+	Envelope
+}
+
+// WsHandler runs every text frame of a hub session as a MsgRequest.
+func (d *Dispatcher) WsHandler() WsHandler {
+	return func(s *WsSession, op websocket.Opcode, msg buffer.IoBuffer) {
+		var req MsgRequest
+		if op != websocket.OpText || json.Unmarshal(msg.Bytes(), &req) != nil {
+			s.Logger.InfoWF("ws bad message", zap.Int("op", int(op)), zap.Int("len", msg.Len()))
+			_ = s.Send(wsReply, MsgReply{Envelope: errorEnvelope(s.Logger, BadRequest("bad message"))})
+			return
+		}
+		env := d.Dispatch(s.Context(), s.Logger, req.MsgId, req.Data)
+		_ = s.Send(wsReply, MsgReply{MsgId: req.MsgId, Seq: req.Seq, Envelope: env})
+	}
+}
@@ -0,0 +1,157 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/auth"
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"github.com/Xbzzy/client_demo/server_demo/common/websocket"
+)
+
+type addReq struct {
+	A, B int
+}
+
+func (r *addReq) Validate() error {
+	if r.A < 0 {
+		return errors.New("a is negative")
+	}
+	return nil
+}
+
+type addResp struct {
+	Sum   int    `json:"sum"`
+	Uid   uint64 `json:"uid"`
+	LogId int64  `json:"logId"`
+}
+
+func newTestDispatcher() *Dispatcher {
+	d := NewDispatcher()
+	HandleMsg(d, 100, "Add", func(ctx context.Context, logger simplelog.LogI, req *addReq) (*addResp, error) {
+		if logger.GetUid() != UidFrom(ctx) {
+			return nil, errors.New("logger misses the uid")
+		}
+		return &addResp{Sum: req.A + req.B, Uid: UidFrom(ctx), LogId: logger.GetLogId()}, nil
+	})
+	HandleMsg(d, 101, "Fail", func(context.Context, simplelog.LogI, *struct{}) (*struct{}, error) {
+		return nil, errors.New("db down")
+	})
+	HandleMsg(d, 102, "Panic", func(context.Context, simplelog.LogI, *struct{}) (*struct{}, error) {
+		panic("boom")
+	})
+	return d
+}
+
+func TestDispatch(t *testing.T) {
+	d := newTestDispatcher()
+	l := &simplelog.ZapLog{}
+	l.SetLogId(1)
+	ctx := WithSession(context.Background(), &auth.Claims{Uid: 42})
+
+	var resp addResp
+	env := d.Dispatch(ctx, l, 100, []byte(`{"A":1,"B":2}`))
+	data, _ := json.Marshal(env.Data)
+	_ = json.Unmarshal(data, &resp)
+	if env.Code != CodeOk || resp.Sum != 3 || resp.Uid != 42 || resp.LogId != env.LogId || env.LogId == 1 {
+		t.Errorf("unexpected dispatch %+v %+v", env, resp)
+	}
+	if l.GetLogId() != 1 {
+		t.Error("expect the connection logger untouched")
+	}
+
+	for _, c := range []struct {
+		msgId   uint32
+		payload string
+		code    int
+	}{
+		{100, "", CodeOk},
+		{100, `{"A":`, http.StatusBadRequest},
+		{100, `{"A":-1}`, http.StatusBadRequest},
+		{101, "", http.StatusInternalServerError},
+		{102, "", http.StatusInternalServerError},
+		{999, "", http.StatusNotFound},
+	} {
+		if env := d.Dispatch(ctx, l, c.msgId, []byte(c.payload)); env.Code != c.code || env.LogId == 0 {
+			t.Errorf("msg %d %q: expect code %d, got %+v", c.msgId, c.payload, c.code, env)
+		}
+	}
+
+	if ids := d.Ids(); len(ids) != 3 || ids[0] != 100 || ids[2] != 102 {
+		t.Errorf("unexpected ids %v", ids)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("expect panic for a duplicate name")
+		}
+	}()
+	HandleMsg(d, 103, "Add", func(context.Context, simplelog.LogI, *struct{}) (int, error) { return 0, nil })
+}
+
+func TestDispatchHttp(t *testing.T) {
+	rt, _ := newAuthRouter(t)
+	rt.POST("/msg/{id}", newTestDispatcher().HttpHandler(), RequireLogin())
+	guest := guestLogin(t, rt, "test")
+
+	for _, c := range []struct {
+		path, body string
+		status     int
+	}{
+		{"/msg/100", `{"A":1,"B":2}`, http.StatusOK},
+		{"/msg/Add", `{"A":1,"B":2}`, http.StatusOK},
+		{"/msg/Add", `{"A":-1}`, http.StatusBadRequest},
+		{"/msg/Panic", "", http.StatusInternalServerError},
+		{"/msg/Nope", "", http.StatusNotFound},
+	} {
+		w := authRequest(rt, http.MethodPost, c.path, guest.Token, c.body)
+
+		var resp addResp
+		_ = json.Unmarshal(w.Body.Bytes(), &Envelope{Data: &resp})
+		if w.Code != c.status || (c.status == http.StatusOK && (resp.Sum != 3 || resp.Uid != guest.Uid)) {
+			t.Errorf("%s: expect %d, got %d %s", c.path, c.status, w.Code, w.Body.String())
+		}
+		// the handler logs with the request id the client sees
+		if c.status == http.StatusOK && w.Header().Get(RequestIdHeader) == "" {
+			t.Errorf("%s: missing request id", c.path)
+		}
+	}
+}
+
+func TestDispatchWs(t *testing.T) {
+	hub, signer, srv := newWsServer(t)
+	hub.SetHandler(newTestDispatcher().WsHandler())
+	token, _, _ := signer.Issue(42, "test")
+	conn, br, status := wsDial(t, srv, token)
+	if status != http.StatusSwitchingProtocols {
+		t.Fatalf("expect 101, got %d", status)
+	}
+
+	for _, c := range []struct {
+		frame string
+		seq   uint64
+		code  int
+	}{
+		{`{"msgId":100,"seq":7,"data":{"A":1,"B":2}}`, 7, CodeOk},
+		{`{"msgId":102,"seq":8}`, 8, http.StatusInternalServerError},
+		{`not json`, 0, http.StatusBadRequest},
+	} {
+		wsSend(conn, websocket.OpText, c.frame)
+		op, data := wsRecv(t, br)
+		var resp addResp
+		var reply struct {
+			Type string   `json:"type"`
+			Data MsgReply `json:"data"`
+		}
+		reply.Data.Data = &resp
+		if err := json.Unmarshal(data, &reply); op != websocket.OpText || err != nil || reply.Type != wsReply ||
+			reply.Data.Seq != c.seq || reply.Data.Code != c.code {
+			t.Errorf("%s: unexpected reply %s", c.frame, data)
+		}
+		if c.code == CodeOk && (resp.Sum != 3 || resp.Uid != 42) {
+			t.Errorf("unexpected result %+v", resp)
+		}
+	}
+}
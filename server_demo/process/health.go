@@ -0,0 +1,164 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:56
+@Description: liveness and readiness probes for the load balancer
+*/
+
+package process
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"go.uber.org/zap"
+)
+
+const DefaultCheckTimeout = 2 * time.Second
+
+// HealthCheck returns nil when the subsystem is fine, ctx ends at the probe deadline.
+type HealthCheck func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check HealthCheck
+}
+
+type checkResult struct {
+	Name  string        `json:"name"`
+	Ok    bool          `json:"ok"`
+	Error string        `json:"error,omitempty"`
+	Cost  time.Duration `json:"costNs"`
+}
+
+type healthBody struct {
+	Status string        `json:"status"` // ok, fail or stopping
+	Checks []checkResult `json:"checks"`
+}
+
+// Health is the check registry, subsystems add probes when they are created.
+// Liveness failing means restart the process, readiness failing means send no traffic.
+type Health struct {
+	stopping func() bool
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	live    []namedCheck
+	ready   []namedCheck
+	failing map[string]bool // to log only when a check flips
+}
+
+// NewHealth fails readiness once stopping returns true, pass Lifecycle.Stopping.
+func NewHealth(stopping func() bool) *Health {
+	return &Health{stopping: stopping, timeout: DefaultCheckTimeout, failing: make(map[string]bool)}
+}
+
+// Liveness adds a check of /healthz, keep these cheap and about the process itself.
+func (h *Health) Liveness(name string, check HealthCheck) {
+	h.mu.Lock()
+	h.live = append(h.live, namedCheck{name: name, check: check})
+	h.mu.Unlock()
+}
+
+// Readiness adds a check of /readyz, e.g. dependencies and whether new players are accepted.
+func (h *Health) Readiness(name string, check HealthCheck) {
+	h.mu.Lock()
+	h.ready = append(h.ready, namedCheck{name: name, check: check})
+	h.mu.Unlock()
+}
+
+// run executes checks in parallel, each bounded by the check timeout.
+func (h *Health) run(ctx context.Context, logger simplelog.LogI, checks []namedCheck) ([]checkResult, bool) {
+	results := make([]checkResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c namedCheck) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, h.timeout)
+			defer cancel()
+
+			start := time.Now()
+			errCh := make(chan error, 1)
+			go func() { errCh <- c.check(ctx) }()
+			var err error
+			select {
+			case err = <-errCh:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+			results[i] = checkResult{Name: c.name, Ok: err == nil, Cost: time.Since(start)}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}(i, c)
+	}
+	wg.Wait()
+
+	ok := true
+	h.mu.Lock()
+	for _, r := range results {
+		ok = ok && r.Ok
+		if h.failing[r.Name] == !r.Ok {
+			continue
+		}
+		h.failing[r.Name] = !r.Ok
+		if r.Ok {
+			logger.InfoWF("health check recovered", zap.String("check", r.Name))
+		} else {
+			logger.WarnWF("health check fail", zap.String("check", r.Name), zap.String("error", r.Error))
+		}
+	}
+	h.mu.Unlock()
+	return results, ok
+}
+
+func (h *Health) handler(ready bool) HttpHandler {
+	return func(logger simplelog.LogI, _ Params, w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		if ready && h.stopping != nil && h.stopping() {
+			writeJson(logger, w, http.StatusServiceUnavailable, Envelope{Code: http.StatusServiceUnavailable,
+				Msg: "stopping", LogId: logId(logger), Data: healthBody{Status: "stopping", Checks: []checkResult{}}})
+			return
+		}
+
+		h.mu.Lock()
+		checks := append([]namedCheck(nil), h.live...)
+		if ready {
+			checks = append(checks, h.ready...)
+		}
+		h.mu.Unlock()
+
+		results, ok := h.run(r.Context(), logger, checks)
+		if !ok {
+			writeJson(logger, w, http.StatusServiceUnavailable, Envelope{Code: http.StatusServiceUnavailable,
+				Msg: "fail", LogId: logId(logger), Data: healthBody{Status: "fail", Checks: results}})
+			return
+		}
+		WriteJson(logger, w, healthBody{Status: "ok", Checks: results})
+	}
+}
+
+// Register mounts GET /healthz (liveness) and /readyz (liveness and readiness checks).
+// Probes come from few addresses every few seconds, so they skip the rate limit.
+func (h *Health) Register(rt *Router) {
+	rt.GET("/healthz", h.handler(false), SkipRateLimit())
+	rt.GET("/readyz", h.handler(true), SkipRateLimit())
+}
+
+// DirWritable checks a file can be created in dir, e.g. the log directory of RotateFile.
+func DirWritable(dir string) HealthCheck {
+	return func(context.Context) error {
+		f, err := os.CreateTemp(dir, ".healthz-*")
+		if err != nil {
+			return err
+		}
+		name := f.Name()
+		_, err = f.Write([]byte("ok"))
+		return errors.Join(err, f.Close(), os.Remove(name))
+	}
+}
@@ -0,0 +1,82 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthReadiness(t *testing.T) {
+	var stopping atomic.Bool
+	var roomsOk atomic.Bool
+	roomsOk.Store(true)
+
+	h := NewHealth(stopping.Load)
+	h.timeout = 50 * time.Millisecond
+	h.Liveness("self", func(context.Context) error { return nil })
+	h.Readiness("log_dir", DirWritable(t.TempDir()))
+	h.Readiness("rooms", func(context.Context) error {
+		if !roomsOk.Load() {
+			return errors.New("not accepting")
+		}
+		return nil
+	})
+	rt := newTestRouter()
+	h.Register(rt)
+
+	if w := doRequest(rt, http.MethodGet, "/readyz"); w.Code != http.StatusOK {
+		t.Fatalf("expect ready, got %d %s", w.Code, w.Body.String())
+	}
+
+	roomsOk.Store(false)
+	w := doRequest(rt, http.MethodGet, "/readyz")
+	var body healthBody
+	_ = json.Unmarshal(w.Body.Bytes(), &Envelope{Data: &body})
+	if w.Code != http.StatusServiceUnavailable || body.Status != "fail" || len(body.Checks) != 3 ||
+		body.Checks[2].Ok || body.Checks[2].Error != "not accepting" {
+		t.Errorf("expect rooms failing, got %d %s", w.Code, w.Body.String())
+	}
+	if w = doRequest(rt, http.MethodGet, "/healthz"); w.Code != http.StatusOK {
+		t.Errorf("expect liveness unaffected by readiness, got %d", w.Code)
+	}
+
+	roomsOk.Store(true)
+	stopping.Store(true)
+	if w = doRequest(rt, http.MethodGet, "/readyz"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expect not ready while stopping, got %d", w.Code)
+	}
+}
+
+func TestHealthCheckTimeout(t *testing.T) {
+	h := NewHealth(nil)
+	h.timeout = 20 * time.Millisecond
+	h.Liveness("stuck", func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(time.Second) // ignores ctx for too long
+		return nil
+	})
+	rt := newTestRouter()
+	h.Register(rt)
+
+	start := time.Now()
+	if w := doRequest(rt, http.MethodGet, "/healthz"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expect timeout to fail, got %d", w.Code)
+	}
+	if cost := time.Since(start); cost > 500*time.Millisecond {
+		t.Errorf("expect probe bounded by the timeout, took %v", cost)
+	}
+}
+
+func TestDirWritable(t *testing.T) {
+	if err := DirWritable(t.TempDir())(context.Background()); err != nil {
+		t.Error(err)
+	}
+	if err := DirWritable(filepath.Join(t.TempDir(), "missing"))(context.Background()); err == nil {
+		t.Error("expect missing dir to fail")
+	}
+}
@@ -0,0 +1,155 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:40
+@Description: typed json handlers and the response envelope
+*/
+
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"go.uber.org/zap"
+)
+
+const (
+	CodeOk = 0
+)
+
+// Envelope is the body of every json response, LogId matches the logID field in our logs.
+type Envelope struct {
+	Code  int         `json:"code"`
+	Msg   string      `json:"msg"`
+	LogId int64       `json:"logId,string"`
+	Data  interface{} `json:"data,omitempty"`
+}
+
+// Error is returned by json handlers to pick the http status and envelope code.
+type Error struct {
+	Status int
+	Code   int
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %d %s", e.Status, e.Code, e.Msg)
+}
+
+// NewError returns an Error whose code is the http status.
+func NewError(status int, msg string) *Error {
+	return &Error{Status: status, Code: status, Msg: msg}
+}
+
+func BadRequest(msg string) *Error {
+	return NewError(http.StatusBadRequest, msg)
+}
+
+func NotFound(msg string) *Error {
+	return NewError(http.StatusNotFound, msg)
+}
+
+func Unauthorized(msg string) *Error {
+	return NewError(http.StatusUnauthorized, msg)
+}
+
+func Forbidden(msg string) *Error {
+	return NewError(http.StatusForbidden, msg)
+}
+
+func Conflict(msg string) *Error {
+	return NewError(http.StatusConflict, msg)
+}
+
+// Validator is implemented by request structs that check themselves after decode.
+type Validator interface {
+	Validate() error
+}
+
+// JsonFunc is the business function behind JsonHandler.
+type JsonFunc[Req any, Resp any] func(ctx context.Context, logger simplelog.LogI, params Params, req *Req) (Resp, error)
+
+// JsonHandler decodes the body into Req, validates it, runs fn and writes the envelope.
+// An empty body leaves Req zero valued, so GET handlers can use struct{}.
+func JsonHandler[Req any, Resp any](fn JsonFunc[Req, Resp]) HttpHandler {
+	return func(logger simplelog.LogI, params Params, w http.ResponseWriter, r *http.Request) {
+		req := new(Req)
+		if err := decodeBody(r, req); err != nil {
+			WriteError(logger, w, err)
+			return
+		}
+		if v, ok := interface{}(req).(Validator); ok {
+			if err := v.Validate(); err != nil {
+				WriteError(logger, w, BadRequest(err.Error()))
+				return
+			}
+		}
+
+		resp, err := fn(r.Context(), logger, params, req)
+		if err != nil {
+			WriteError(logger, w, err)
+			return
+		}
+		WriteJson(logger, w, resp)
+	}
+}
+
+func decodeBody(r *http.Request, v interface{}) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == nil || err == io.EOF {
+		return nil
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return NewError(http.StatusRequestEntityTooLarge, "request body too large")
+	}
+	return BadRequest("bad request body: " + err.Error())
+}
+
+// WriteJson writes data in a success envelope.
+func WriteJson(logger simplelog.LogI, w http.ResponseWriter, data interface{}) {
+	writeJson(logger, w, http.StatusOK, Envelope{Code: CodeOk, Msg: "ok", LogId: logId(logger), Data: data})
+}
+
+// WriteError writes err in an error envelope, errors other than *Error become a 500 and are logged.
+func WriteError(logger simplelog.LogI, w http.ResponseWriter, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		if logger != nil {
+			logger.WarnWF("http handler error", zap.Error(err))
+		}
+		e = NewError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	writeJson(logger, w, e.Status, Envelope{Code: e.Code, Msg: e.Msg, LogId: logId(logger)})
+}
+
+func writeJsonError(logger simplelog.LogI, w http.ResponseWriter, status int, msg string) {
+	WriteError(logger, w, NewError(status, msg))
+}
+
+func writeJson(logger simplelog.LogI, w http.ResponseWriter, status int, v interface{}) {
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil && logger != nil {
+		logger.WarnWF("write json fail", zap.Int("status", status), zap.Error(err))
+	}
+}
+
+func logId(logger simplelog.LogI) int64 {
+	if logger == nil {
+		return 0
+	}
+	return logger.GetLogId()
+}
@@ -0,0 +1,95 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+)
+
+type moveReq struct {
+	Cell int `json:"cell"`
+}
+
+func (m *moveReq) Validate() error {
+	if m.Cell < 0 || m.Cell > 8 {
+		return errors.New("cell out of range")
+	}
+	return nil
+}
+
+type moveResp struct {
+	Room string `json:"room"`
+	Cell int    `json:"cell"`
+}
+
+func newMoveRouter() *Router {
+	rt := newTestRouter()
+	rt.POST("/room/{id}/move", JsonHandler(func(_ context.Context, _ simplelog.LogI, params Params, req *moveReq) (*moveResp, error) {
+		switch params.Get("id") {
+		case "busy":
+			return nil, Conflict("not your turn")
+		case "broken":
+			return nil, errors.New("db down")
+		}
+		return &moveResp{Room: params.Get("id"), Cell: req.Cell}, nil
+	}))
+	return rt
+}
+
+func postJson(h http.Handler, path, body string) (*httptest.ResponseRecorder, Envelope) {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	var env Envelope
+	_ = json.Unmarshal(w.Body.Bytes(), &env)
+	return w, env
+}
+
+func TestJsonHandlerOk(t *testing.T) {
+	var resp moveResp
+	w := httptest.NewRecorder()
+	newMoveRouter().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/room/r1/move", strings.NewReader(`{"cell":4}`)))
+	env := Envelope{Data: &resp}
+	if err := json.Unmarshal(w.Body.Bytes(), &env); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || env.Code != CodeOk || env.LogId == 0 || resp.Room != "r1" || resp.Cell != 4 {
+		t.Errorf("unexpected response %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestJsonHandlerErrors(t *testing.T) {
+	rt := newMoveRouter()
+	cases := []struct {
+		path, body string
+		status     int
+	}{
+		{"/room/r1/move", `{"cell":`, http.StatusBadRequest},
+		{"/room/r1/move", `{"cell":9}`, http.StatusBadRequest},
+		{"/room/busy/move", `{"cell":1}`, http.StatusConflict},
+		{"/room/broken/move", `{"cell":1}`, http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		w, env := postJson(rt, c.path, c.body)
+		if w.Code != c.status || env.Code != c.status || env.LogId == 0 || env.Msg == "" {
+			t.Errorf("%s %s: expect %d, got %d %s", c.path, c.body, c.status, w.Code, w.Body.String())
+		}
+	}
+
+	// internal errors are not leaked to the client
+	if _, env := postJson(rt, "/room/broken/move", `{"cell":1}`); strings.Contains(env.Msg, "db") {
+		t.Errorf("expect generic msg, got %s", env.Msg)
+	}
+}
+
+func TestNotFoundUsesEnvelope(t *testing.T) {
+	w, env := postJson(newMoveRouter(), "/nothing", "")
+	if w.Code != http.StatusNotFound || env.Code != http.StatusNotFound || env.LogId == 0 {
+		t.Errorf("unexpected 404 %s", w.Body.String())
+	}
+}
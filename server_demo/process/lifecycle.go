@@ -0,0 +1,229 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:36
+@Description: ordered start/stop hooks and graceful shutdown
+*/
+
+package process
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"go.uber.org/zap"
+)
+
+// Hook is one step of the server lifecycle, both funcs are optional.
+type Hook struct {
+	Name    string
+	OnStart func() error
+	OnStop  func(ctx context.Context) error
+}
+
+// Lifecycle starts hooks in the order they were appended and stops them in reverse,
+// so append the logger first and the listeners last.
+type Lifecycle struct {
+	logger simplelog.LogI
+
+	mu      sync.Mutex
+	hooks   []Hook
+	started int // hooks[:started] have run OnStart
+
+	reloads    []func()
+	drainDelay time.Duration
+
+	stopping atomic.Bool
+	errCh    chan error
+}
+
+func NewLifecycle(l simplelog.LogI) *Lifecycle {
+	return &Lifecycle{logger: l, errCh: make(chan error, 1)}
+}
+
+func (lc *Lifecycle) Append(h Hook) {
+	lc.mu.Lock()
+	lc.hooks = append(lc.hooks, h)
+	lc.mu.Unlock()
+}
+
+// OnReload registers fn to run on SIGHUP, in registration order.
+func (lc *Lifecycle) OnReload(fn func()) {
+	lc.mu.Lock()
+	lc.reloads = append(lc.reloads, fn)
+	lc.mu.Unlock()
+}
+
+func (lc *Lifecycle) reload() {
+	lc.mu.Lock()
+	reloads := append([]func(){}, lc.reloads...)
+	lc.mu.Unlock()
+
+	for _, fn := range reloads {
+		fn()
+	}
+}
+
+// SetDrainDelay makes Run wait d between the stop signal and Stop, readiness already fails
+// meanwhile so load balancers move traffic away while the listeners still accept.
+func (lc *Lifecycle) SetDrainDelay(d time.Duration) {
+	lc.mu.Lock()
+	lc.drainDelay = d
+	lc.mu.Unlock()
+}
+
+// Stopping reports whether shutdown has started.
+func (lc *Lifecycle) Stopping() bool {
+	return lc.stopping.Load()
+}
+
+// Fail makes Run stop the server, used by hooks whose background work dies.
+func (lc *Lifecycle) Fail(err error) {
+	select {
+	case lc.errCh <- err:
+	default:
+	}
+}
+
+// Start runs OnStart of every hook, on failure the already started hooks are stopped.
+func (lc *Lifecycle) Start() error {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	for lc.started < len(lc.hooks) {
+		h := lc.hooks[lc.started]
+		if h.OnStart != nil {
+			if err := h.OnStart(); err != nil {
+				lc.logger.ErrorWF("lifecycle start fail", zap.String("hook", h.Name), zap.Error(err))
+				lc.stopping.Store(true)
+				lc.stopStarted(context.Background())
+				return err
+			}
+		}
+		lc.logger.InfoWF("lifecycle started", zap.String("hook", h.Name))
+		lc.started++
+	}
+	return nil
+}
+
+// Stop runs OnStop of the started hooks in reverse order, ctx bounds the whole shutdown.
+func (lc *Lifecycle) Stop(ctx context.Context) error {
+	lc.stopping.Store(true)
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	return lc.stopStarted(ctx)
+}
+
+func (lc *Lifecycle) stopStarted(ctx context.Context) error {
+	var errs []error
+	for ; lc.started > 0; lc.started-- {
+		h := lc.hooks[lc.started-1]
+		if h.OnStop == nil {
+			continue
+		}
+		lc.logger.InfoWF("lifecycle stopping", zap.String("hook", h.Name))
+		if err := h.OnStop(ctx); err != nil {
+			errs = append(errs, err)
+			lc.logger.WarnWF("lifecycle stop fail", zap.String("hook", h.Name), zap.Error(err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run starts every hook, blocks until SIGINT/SIGTERM or Fail, then stops with timeout.
+// SIGHUP runs the OnReload funcs and keeps serving.
+func (lc *Lifecycle) Run(timeout time.Duration) error {
+	if err := lc.Start(); err != nil {
+		return err
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+
+	var runErr error
+wait:
+	for {
+		select {
+		case sig := <-sigCh:
+			lc.logger.InfoWF("lifecycle got signal", zap.String("signal", sig.String()))
+			if sig == syscall.SIGHUP {
+				lc.reload()
+				continue
+			}
+			lc.drain(sigCh)
+			break wait
+		case runErr = <-lc.errCh:
+			lc.logger.ErrorWF("lifecycle failed", zap.Error(runErr))
+			break wait
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return errors.Join(runErr, lc.Stop(ctx))
+}
+
+// drain flips Stopping and waits the drain delay, a second SIGINT/SIGTERM skips the wait.
+func (lc *Lifecycle) drain(sigCh <-chan os.Signal) {
+	lc.stopping.Store(true)
+	lc.mu.Lock()
+	delay := lc.drainDelay
+	lc.mu.Unlock()
+	if delay <= 0 {
+		return
+	}
+
+	lc.logger.InfoWF("lifecycle draining", zap.Duration("delay", delay))
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return
+		case sig := <-sigCh:
+			if sig != syscall.SIGHUP {
+				lc.logger.InfoWF("lifecycle drain skipped", zap.String("signal", sig.String()))
+				return
+			}
+		}
+	}
+}
+
+// AppendHttpServer listens on srv.Addr at start, and on stop closes the listener
+// and waits for in-flight handlers until the stop deadline.
+func (lc *Lifecycle) AppendHttpServer(name string, srv *http.Server) {
+	lc.Append(Hook{
+		Name: name,
+		OnStart: func() error {
+			ln, err := net.Listen("tcp", srv.Addr)
+			if err != nil {
+				return err
+			}
+			lc.logger.InfoWF("http server listen", zap.String("name", name), zap.String("addr", ln.Addr().String()))
+			go func() {
+				if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+					lc.Fail(err)
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			err := srv.Shutdown(ctx)
+			if err != nil {
+				// deadline passed with handlers still running, cut them off
+				_ = srv.Close()
+			}
+			return err
+		},
+	})
+}
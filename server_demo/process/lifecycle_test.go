@@ -0,0 +1,92 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+)
+
+func traceHook(trace *[]string, name string, startErr error) Hook {
+	return Hook{
+		Name: name,
+		OnStart: func() error {
+			*trace = append(*trace, "start "+name)
+			return startErr
+		},
+		OnStop: func(ctx context.Context) error {
+			*trace = append(*trace, "stop "+name)
+			return nil
+		},
+	}
+}
+
+func TestLifecycleOrder(t *testing.T) {
+	var trace []string
+	lc := NewLifecycle(&simplelog.ZapLog{})
+	lc.Append(traceHook(&trace, "logger", nil))
+	lc.Append(traceHook(&trace, "rooms", nil))
+	lc.Append(traceHook(&trace, "http", nil))
+
+	if err := lc.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if lc.Stopping() {
+		t.Error("expect not stopping after start")
+	}
+	if err := lc.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !lc.Stopping() {
+		t.Error("expect stopping after stop")
+	}
+
+	expect := "start logger,start rooms,start http,stop http,stop rooms,stop logger"
+	if got := strings.Join(trace, ","); got != expect {
+		t.Errorf("expect %s, got %s", expect, got)
+	}
+}
+
+func TestLifecycleStartFailRollsBack(t *testing.T) {
+	var trace []string
+	lc := NewLifecycle(&simplelog.ZapLog{})
+	lc.Append(traceHook(&trace, "logger", nil))
+	lc.Append(traceHook(&trace, "http", errors.New("bind fail")))
+	lc.Append(traceHook(&trace, "never", nil))
+
+	if err := lc.Start(); err == nil {
+		t.Fatal("expect start error")
+	}
+	expect := "start logger,start http,stop logger"
+	if got := strings.Join(trace, ","); got != expect {
+		t.Errorf("expect %s, got %s", expect, got)
+	}
+}
+
+func TestLifecycleDrain(t *testing.T) {
+	lc := NewLifecycle(&simplelog.ZapLog{})
+	lc.SetDrainDelay(time.Hour)
+
+	sigCh := make(chan os.Signal, 2)
+	sigCh <- syscall.SIGHUP // reload during drain keeps waiting
+	sigCh <- syscall.SIGTERM
+	done := make(chan struct{})
+	go func() {
+		lc.drain(sigCh)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expect a second SIGTERM to skip the drain delay")
+	}
+	if !lc.Stopping() {
+		t.Error("expect Stopping during drain")
+	}
+}
@@ -0,0 +1,106 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:42
+@Description: body size limit, handler deadline and slow request log per route
+*/
+
+package process
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultSlowThreshold = 500 * time.Millisecond
+	NoBodyLimit          = -1
+	NoSlowThreshold      = -1
+)
+
+// WithBodyLimit caps the request body of the route, NoBodyLimit disables the router default.
+func WithBodyLimit(n int64) RouteOption {
+	return func(r *Route) {
+		r.bodyLimit = n
+	}
+}
+
+// WithTimeout sets a deadline on the request context, handlers must watch ctx.Done.
+func WithTimeout(d time.Duration) RouteOption {
+	return func(r *Route) {
+		r.timeout = d
+	}
+}
+
+// WithSlowThreshold overrides the router slow threshold for the route, NoSlowThreshold turns the log off.
+func WithSlowThreshold(d time.Duration) RouteOption {
+	return func(r *Route) {
+		r.slowThreshold = d
+	}
+}
+
+// SetBodyLimit sets the body limit of routes without WithBodyLimit, 0 means unlimited.
+func (rt *Router) SetBodyLimit(n int64) {
+	rt.bodyLimit.Store(n)
+}
+
+// SetSlowThreshold sets the duration above which a request is logged as slow, 0 disables it.
+func (rt *Router) SetSlowThreshold(d time.Duration) {
+	rt.slowThreshold.Store(int64(d))
+}
+
+func (r *Route) limits() (bodyLimit int64, slow time.Duration) {
+	bodyLimit, slow = r.bodyLimit, r.slowThreshold
+	if bodyLimit == 0 && r.router != nil {
+		bodyLimit = r.router.bodyLimit.Load()
+	}
+	if slow == 0 && r.router != nil {
+		slow = time.Duration(r.router.slowThreshold.Load())
+	}
+	return
+}
+
+// LimitMiddleware applies the body limit and deadline of the route and logs requests slower than the threshold.
+func LimitMiddleware(route *Route, next HttpHandler) HttpHandler {
+	return func(logger simplelog.LogI, params Params, w http.ResponseWriter, r *http.Request) {
+		bodyLimit, slow := route.limits()
+
+		if bodyLimit > 0 && r.Body != nil && r.Body != http.NoBody {
+			if r.ContentLength > bodyLimit {
+				logger.InfoWF("http body too large", zap.String("pattern", route.Pattern),
+					zap.Int64("contentLength", r.ContentLength), zap.Int64("limit", bodyLimit))
+				writeJsonError(logger, w, http.StatusRequestEntityTooLarge, "request body too large")
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, bodyLimit)
+		}
+
+		if route.timeout > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), route.timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+
+		start := time.Now()
+		next(logger, params, w, r)
+		cost := time.Since(start)
+
+		if route.timeout > 0 && errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+			logger.WarnWF("http handler deadline exceeded", zap.String("pattern", route.Pattern),
+				zap.Duration("timeout", route.timeout), zap.Duration("cost", cost))
+			if _, started := Status(w); !started {
+				writeJsonError(logger, w, http.StatusServiceUnavailable, "handler timeout")
+			}
+		}
+
+		if slow > 0 && cost > slow {
+			status, _ := Status(w)
+			logger.WarnWF("http slow request", zap.String("method", r.Method), zap.String("pattern", route.Pattern),
+				zap.Duration("cost", cost), zap.Duration("threshold", slow), zap.Int("status", status))
+		}
+	}
+}
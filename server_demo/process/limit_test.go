@@ -0,0 +1,69 @@
+package process
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+)
+
+func TestBodyLimit(t *testing.T) {
+	rt := newTestRouter()
+	rt.SetBodyLimit(8)
+	echo := JsonHandler(func(_ context.Context, _ simplelog.LogI, _ Params, req *map[string]string) (*map[string]string, error) {
+		return req, nil
+	})
+	rt.POST("/small", echo)
+	rt.POST("/big", echo, WithBodyLimit(NoBodyLimit))
+	rt.POST("/medium", echo, WithBodyLimit(64))
+
+	body := `{"name":"a long enough value"}`
+	cases := map[string]int{"/small": http.StatusRequestEntityTooLarge, "/big": http.StatusOK, "/medium": http.StatusOK}
+	for path, status := range cases {
+		if w, _ := postJson(rt, path, body); w.Code != status {
+			t.Errorf("%s: expect %d, got %d %s", path, status, w.Code, w.Body.String())
+		}
+	}
+
+	// without Content-Length the limit is enforced while reading
+	req := httptest.NewRequest(http.MethodPost, "/small", io.MultiReader(strings.NewReader(body)))
+	req.ContentLength = -1
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expect 413 for chunked body, got %d", w.Code)
+	}
+}
+
+func TestHandlerTimeout(t *testing.T) {
+	rt := newTestRouter()
+	rt.GET("/wait", func(_ simplelog.LogI, _ Params, _ http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}, WithTimeout(20*time.Millisecond))
+
+	if w := doRequest(rt, http.MethodGet, "/wait"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expect 503, got %d", w.Code)
+	}
+}
+
+func TestRouteLimits(t *testing.T) {
+	rt := newTestRouter()
+	rt.SetBodyLimit(100)
+	rt.SetSlowThreshold(time.Second)
+	handler := func(simplelog.LogI, Params, http.ResponseWriter, *http.Request) {}
+
+	a := rt.GET("/a", handler)
+	b := rt.GET("/b", handler, WithBodyLimit(10), WithSlowThreshold(time.Millisecond))
+
+	if limit, slow := a.limits(); limit != 100 || slow != time.Second {
+		t.Errorf("expect router defaults, got %d %v", limit, slow)
+	}
+	if limit, slow := b.limits(); limit != 10 || slow != time.Millisecond {
+		t.Errorf("expect route overrides, got %d %v", limit, slow)
+	}
+}
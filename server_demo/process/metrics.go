@@ -0,0 +1,131 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:53
+@Description: http, message, buffer pool and log file metrics
+*/
+
+package process
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/buffer"
+	"github.com/Xbzzy/client_demo/server_demo/common/metrics"
+	"github.com/Xbzzy/client_demo/server_demo/common/rotatefile"
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"go.uber.org/zap"
+)
+
+// unmatchedPattern labels requests no route matched (404, 405).
+const unmatchedPattern = "unmatched"
+
+var (
+	httpRequests = metrics.Default.NewCounterVec("http_requests_total",
+		"Http requests by route pattern and status.", "method", "pattern", "status")
+	httpDuration = metrics.Default.NewHistogramVec("http_request_duration_seconds",
+		"Http request latency by route pattern.", nil, "method", "pattern")
+	httpInFlight = metrics.Default.NewGauge("http_requests_in_flight",
+		"Http requests being served.")
+	httpPanics = metrics.Default.NewCounterVec("http_panics_total",
+		"Handler panics by route pattern.", "method", "pattern")
+	msgRequests = metrics.Default.NewCounterVec("msg_requests_total",
+		"Dispatcher messages by name and envelope code, http calls are counted here too.", "msg", "code")
+	msgPanics = metrics.Default.NewCounterVec("msg_panics_total",
+		"Message handler panics by name.", "msg")
+)
+
+func init() {
+	gets := metrics.Default.NewFuncVec("buffer_pool_gets_total", "Buffers taken from the pool.", metrics.TypeCounter, "pool")
+	puts := metrics.Default.NewFuncVec("buffer_pool_puts_total", "Buffers given back to the pool.", metrics.TypeCounter, "pool")
+	misses := metrics.Default.NewFuncVec("buffer_pool_misses_total", "Gets that had to allocate.", metrics.TypeCounter, "pool")
+	pools := []struct {
+		name  string
+		stats func() buffer.PoolStats
+	}{{"io", buffer.IoBufferPoolStats}, {"bytes", buffer.BytesPoolStats}}
+	for _, p := range pools {
+		stats := p.stats
+		gets.Set(func() float64 { return float64(stats().Gets) }, p.name)
+		puts.Set(func() float64 { return float64(stats().Puts) }, p.name)
+		misses.Set(func() float64 { return float64(stats().Misses) }, p.name)
+	}
+
+	metrics.Default.NewFuncVec("ws_sessions", "Open websocket sessions of DefaultHub.", metrics.TypeGauge).
+		Set(func() float64 { return float64(DefaultHub.Count()) })
+}
+
+// methodLabel keeps the label set small, clients can send any method to ANY routes.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "OTHER"
+}
+
+// MetricsMiddleware counts requests and latency per pattern, it goes first so it also sees
+// the 500 written by RecoverMiddleware. A hijacked request, e.g. a websocket upgrade, is counted
+// as a 101 when it is hijacked and leaves the in-flight gauge then, its handler keeps running
+// for the whole connection.
+func MetricsMiddleware(route *Route, next HttpHandler) HttpHandler {
+	return func(logger simplelog.LogI, params Params, w http.ResponseWriter, r *http.Request) {
+		httpInFlight.Add(1)
+		start := time.Now()
+		hijacked := false
+		onHijack(w, func() {
+			hijacked = true
+			httpInFlight.Add(-1)
+			observeRequest(r.Method, route.Pattern, http.StatusSwitchingProtocols, time.Since(start))
+		})
+		next(logger, params, w, r)
+		if hijacked {
+			return
+		}
+
+		httpInFlight.Add(-1)
+		status, _ := Status(w)
+		observeRequest(r.Method, route.Pattern, status, time.Since(start))
+	}
+}
+
+func observeRequest(method, pattern string, status int, cost time.Duration) {
+	method = methodLabel(method)
+	httpRequests.With(method, pattern, strconv.Itoa(status)).Inc()
+	httpDuration.With(method, pattern).Observe(cost.Seconds())
+}
+
+type fileStatser interface {
+	FileStats() (rotatefile.Stats, bool)
+}
+
+// RegisterLogMetrics exports the counters of the log file behind l, call it once.
+func RegisterLogMetrics(l simplelog.LogI) {
+	fs, ok := l.(fileStatser)
+	if !ok {
+		return
+	}
+	stats := func() rotatefile.Stats {
+		s, _ := fs.FileStats()
+		return s
+	}
+	metrics.Default.NewFuncVec("log_file_written_bytes_total", "Bytes written to the log file.", metrics.TypeCounter).
+		Set(func() float64 { return float64(stats().BytesWritten) })
+	metrics.Default.NewFuncVec("log_file_rotations_total", "Log file rotations.", metrics.TypeCounter).
+		Set(func() float64 { return float64(stats().Rotations) })
+	metrics.Default.NewFuncVec("log_file_rotate_failures_total", "Log file rotations that kept the old file.", metrics.TypeCounter).
+		Set(func() float64 { return float64(stats().RotateFailures) })
+	metrics.Default.NewFuncVec("log_file_dropped_writes_total", "Log lines dropped because the write queue was full.", metrics.TypeCounter).
+		Set(func() float64 { return float64(stats().DroppedWrites) })
+}
+
+// metricsHandler serves the registry in the prometheus text format.
+func metricsHandler(reg *metrics.Registry) HttpHandler {
+	return func(logger simplelog.LogI, _ Params, w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := reg.WriteText(w); err != nil {
+			logger.InfoWF("write metrics fail", zap.Error(err))
+		}
+	}
+}
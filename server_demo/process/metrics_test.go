@@ -0,0 +1,72 @@
+package process
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	rt := newTestRouter()
+	rt.GET("/metrics-test/{id}", func(simplelog.LogI, Params, http.ResponseWriter, *http.Request) {})
+	rt.GET("/metrics-test/{id}/boom", func(simplelog.LogI, Params, http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})
+
+	before := httpRequests.With(http.MethodGet, "/metrics-test/{id}", "200").Value()
+	doRequest(rt, http.MethodGet, "/metrics-test/1")
+	doRequest(rt, http.MethodGet, "/metrics-test/2")
+	if got := httpRequests.With(http.MethodGet, "/metrics-test/{id}", "200").Value() - before; got != 2 {
+		t.Errorf("expect 2 requests counted, got %v", got)
+	}
+
+	doRequest(rt, http.MethodGet, "/metrics-test/1/boom")
+	if httpRequests.With(http.MethodGet, "/metrics-test/{id}/boom", "500").Value() != 1 ||
+		httpPanics.With(http.MethodGet, "/metrics-test/{id}/boom").Value() != 1 {
+		t.Error("expect the panic counted as a 500")
+	}
+	if httpInFlight.Value() != 0 {
+		t.Errorf("expect nothing in flight, got %v", httpInFlight.Value())
+	}
+}
+
+func TestMetricsHijacked(t *testing.T) {
+	hub, signer, srv := newWsServer(t)
+	before := httpRequests.With(http.MethodGet, "/ws", "101").Value()
+	token, _, _ := signer.Issue(42, "test")
+	wsDial(t, srv, token)
+	for i := 0; hub.Count() == 0 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the session is still open, its request is done
+	if got := httpRequests.With(http.MethodGet, "/ws", "101").Value() - before; got != 1 {
+		t.Errorf("expect the upgrade counted as a 101, got %v", got)
+	}
+	if httpInFlight.Value() != 0 {
+		t.Errorf("expect an open websocket not in flight, got %v", httpInFlight.Value())
+	}
+}
+
+func TestAdminMetrics(t *testing.T) {
+	admin, _ := newTestAdmin(t, newTestAdminLogger(t))
+	doRequest(admin, http.MethodGet, "/admin/nothing")
+
+	w := doRequest(admin, http.MethodGet, "/admin/metrics")
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("unexpected response %d %v", w.Code, w.Header())
+	}
+	for _, want := range []string{
+		`http_requests_total{method="GET",pattern="unmatched",status="404"}`,
+		"# TYPE http_request_duration_seconds histogram",
+		`buffer_pool_gets_total{pool="io"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expect %s in metrics", want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:34
+@Description: composable middlewares around route handlers
+*/
+
+package process
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/idgen"
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"go.uber.org/zap"
+)
+
+// Middleware wraps next for route, it is applied once when the route chain is built.
+type Middleware func(route *Route, next HttpHandler) HttpHandler
+
+// Chain wraps handler with mws, the first middleware is the outermost.
+func Chain(route *Route, handler HttpHandler, mws ...Middleware) HttpHandler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		handler = mws[i](route, handler)
+	}
+	return handler
+}
+
+// DefaultMiddlewares are installed on every router created by NewRouter.
+func DefaultMiddlewares() []Middleware {
+	return []Middleware{MetricsMiddleware, RecoverMiddleware, RequestIdMiddleware, RequestLogMiddleware, LimitMiddleware}
+}
+
+// WithMiddleware adds mws to a single route, after the router level middlewares.
+func WithMiddleware(mws ...Middleware) RouteOption {
+	return func(r *Route) {
+		r.middlewares = append(r.middlewares, mws...)
+	}
+}
+
+// RequestIdHeader carries the request id, the client may send one and always gets it back.
+const RequestIdHeader = "X-Request-Id"
+
+// maxRequestIdLen is the number of digits of the largest int64.
+const maxRequestIdLen = 19
+
+// requestId returns the client's X-Request-Id when it is a positive int64 written with digits only,
+// otherwise a new id. dropped is the length of a client id that was not usable, 0 when there was none.
+func requestId(r *http.Request) (id int64, dropped int) {
+	clientId := r.Header.Get(RequestIdHeader)
+	if clientId == "" {
+		return idgen.Next(), 0
+	}
+	if len(clientId) <= maxRequestIdLen && strings.Trim(clientId, "0123456789") == "" {
+		if id, err := strconv.ParseInt(clientId, 10, 64); err == nil && id > 0 {
+			return id, 0
+		}
+	}
+	return idgen.Next(), len(clientId)
+}
+
+// RequestIdMiddleware sets the request id as logId, echoes it in X-Request-Id and puts it on the context.
+// A valid client id is used as is, so the client and our logs name the request alike.
+func RequestIdMiddleware(_ *Route, next HttpHandler) HttpHandler {
+	return func(logger simplelog.LogI, params Params, w http.ResponseWriter, r *http.Request) {
+		id, dropped := requestId(r)
+		logger.SetLogId(id)
+		w.Header().Set(RequestIdHeader, strconv.FormatInt(id, 10))
+		if dropped > 0 {
+			logger.DebugWF("client request id replaced", zap.Int("len", dropped))
+		}
+
+		next(logger, params, w, r.WithContext(WithRequestId(r.Context(), id)))
+	}
+}
+
+// redacted replaces credentials in logs, the headers and query params below carry sessions.
+const redacted = "[redacted]"
+
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+const redactedQuery = "access_token"
+
+// logHeader returns a copy of h safe to log, h itself is left untouched.
+func logHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range redactedHeaders {
+		if _, ok := out[name]; ok {
+			out[name] = []string{redacted}
+		}
+	}
+	return out
+}
+
+// logQuery returns the raw query of u safe to log, the order of params is kept.
+func logQuery(u *url.URL) string {
+	if u.RawQuery == "" {
+		return ""
+	}
+	parts := strings.Split(u.RawQuery, "&")
+	for i, part := range parts {
+		key, _, _ := strings.Cut(part, "=")
+		if name, err := url.QueryUnescape(key); err != nil || name == redactedQuery {
+			parts[i] = key + "=" + redacted
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// RequestLogMiddleware logs the start of every request at debug level. It runs before the auth
+// middleware sets the uid, so the per-uid debug override of simplelog starts at the handler and
+// does not cover this line.
+func RequestLogMiddleware(route *Route, next HttpHandler) HttpHandler {
+	return func(logger simplelog.LogI, params Params, w http.ResponseWriter, r *http.Request) {
+		logger.DebugWF("start http", zap.String("pattern", route.Pattern), zap.Any("header", logHeader(r.Header)),
+			zap.String("query", logQuery(r.URL)),
+			zap.Any("host", r.Host), zap.Any("remoteAddr", r.RemoteAddr))
+		next(logger, params, w, r)
+	}
+}
@@ -0,0 +1,124 @@
+package process
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+)
+
+func traceMiddleware(trace *[]string, name string) Middleware {
+	return func(route *Route, next HttpHandler) HttpHandler {
+		return func(logger simplelog.LogI, params Params, w http.ResponseWriter, r *http.Request) {
+			*trace = append(*trace, name+":"+route.Pattern)
+			next(logger, params, w, r)
+		}
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var trace []string
+	rt := newTestRouter()
+	rt.Use(traceMiddleware(&trace, "global1"))
+	rt.GET("/a", func(simplelog.LogI, Params, http.ResponseWriter, *http.Request) {
+		trace = append(trace, "handler")
+	}, WithMiddleware(traceMiddleware(&trace, "route")))
+	// registered after the route, still wraps it
+	rt.Use(traceMiddleware(&trace, "global2"))
+
+	doRequest(rt, http.MethodGet, "/a")
+
+	expect := "global1:/a,global2:/a,route:/a,handler"
+	if got := strings.Join(trace, ","); got != expect {
+		t.Errorf("expect %s, got %s", expect, got)
+	}
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	rt := newTestRouter()
+	called := false
+	deny := func(_ *Route, next HttpHandler) HttpHandler {
+		return func(_ simplelog.LogI, _ Params, w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}
+	rt.GET("/a", func(simplelog.LogI, Params, http.ResponseWriter, *http.Request) {
+		called = true
+	}, WithMiddleware(deny))
+
+	if w := doRequest(rt, http.MethodGet, "/a"); w.Code != http.StatusUnauthorized || called {
+		t.Errorf("expect 401 without calling handler, got %d called=%v", w.Code, called)
+	}
+}
+
+func TestRequestIdMiddleware(t *testing.T) {
+	rt := newTestRouter()
+	var logId, ctxId int64
+	rt.GET("/a", func(logger simplelog.LogI, _ Params, _ http.ResponseWriter, r *http.Request) {
+		logId = logger.GetLogId()
+		ctxId, _ = RequestIdFrom(r.Context())
+	})
+
+	// minted when the client sends none
+	w := doRequest(rt, http.MethodGet, "/a")
+	if logId == 0 || ctxId != logId || w.Header().Get(RequestIdHeader) != strconv.FormatInt(logId, 10) {
+		t.Errorf("expect minted id everywhere, got log %d ctx %d header %s", logId, ctxId, w.Header().Get(RequestIdHeader))
+	}
+
+	// a valid client id is the log id and comes back, anything else is replaced
+	for _, c := range []struct {
+		sent string
+		kept bool
+	}{
+		{"123456789", true},
+		{"9223372036854775807", true},
+		{"9223372036854775808", false},
+		{"0", false},
+		{"+12", false},
+		{"-12", false},
+		{"3f2b8c1e-9a4d-4e0f-8b7a-2c6d5e4f3a21", false},
+		{strings.Repeat("1", 20), false},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/a", nil)
+		req.Header.Set(RequestIdHeader, c.sent)
+		w = httptest.NewRecorder()
+		rt.ServeHTTP(w, req)
+		echoed := w.Header().Get(RequestIdHeader)
+		if logId == 0 || ctxId != logId || echoed != strconv.FormatInt(logId, 10) {
+			t.Errorf("%q: expect one id everywhere, got log %d ctx %d header %s", c.sent, logId, ctxId, echoed)
+		}
+		if (echoed == c.sent) != c.kept {
+			t.Errorf("%q: expect kept %v, got %s", c.sent, c.kept, echoed)
+		}
+	}
+}
+
+func TestRequestLogRedacts(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "Bearer secret")
+	h.Set("Cookie", "sid=secret")
+	h.Set("User-Agent", "client/1.0")
+	logged := logHeader(h)
+	if logged.Get("Authorization") != redacted || logged.Get("Cookie") != redacted || logged.Get("User-Agent") != "client/1.0" {
+		t.Errorf("unexpected logged header %v", logged)
+	}
+	if h.Get("Authorization") != "Bearer secret" {
+		t.Error("expect the request header untouched")
+	}
+
+	for _, c := range []struct{ query, want string }{
+		{"", ""},
+		{"room=1", "room=1"},
+		{"room=1&access_token=secret&x=2", "room=1&access_token=" + redacted + "&x=2"},
+		{"access%5Ftoken=secret", "access%5Ftoken=" + redacted},
+		{"access_token", "access_token=" + redacted},
+	} {
+		if got := logQuery(&url.URL{RawQuery: c.query}); got != c.want {
+			t.Errorf("%q: expect %q, got %q", c.query, c.want, got)
+		}
+	}
+}
@@ -8,28 +8,38 @@ package process
 
 import (
 	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
-	"github.com/Xbzzy/client_demo/server_demo/common/util"
-	"go.uber.org/zap"
 	"net/http"
-	"time"
+	"strings"
 )
 
-func SafeHttpRegister(l simplelog.LogI, pattern string, handler func(simplelog.LogI, http.ResponseWriter, *http.Request)) {
-	http.HandleFunc(pattern, func(writer http.ResponseWriter, request *http.Request) {
-		defer util.CaptureException()
-
-		logger := l.Clone()
-		logger.SetLogId(time.Now().UnixNano())
-
-		logger.DebugWF("start http", zap.String("pattern", pattern), zap.Any("header", request.Header),
-			zap.Any("host", request.Host), zap.Any("remoteAddr", request.RemoteAddr))
+// SafeHttpRegister registers handler on DefaultRouter for any method, pattern may carry {name} params.
+// Like the http.ServeMux it replaced, a pattern ending in / also matches every path below it.
+func SafeHttpRegister(l simplelog.LogI, pattern string, handler func(simplelog.LogI, http.ResponseWriter, *http.Request), opts ...RouteOption) *Route {
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "{rest...}"
+	}
+	return DefaultRouter.handle(l, "", pattern, func(logger simplelog.LogI, _ Params, w http.ResponseWriter, r *http.Request) {
+		handler(logger, w, r)
+	}, opts...)
+}
 
-		handler(logger, writer, request)
-	})
+// SafeHttpHandle registers handler on DefaultRouter for method and pattern.
+func SafeHttpHandle(l simplelog.LogI, method, pattern string, handler HttpHandler, opts ...RouteOption) *Route {
+	return DefaultRouter.handle(l, method, pattern, handler, opts...)
 }
 
-func InitHttp(l simplelog.LogI) {
+func InitHttp(l simplelog.LogI) *Router {
+	DefaultRouter.SetLogger(l)
+
 	SafeHttpRegister(l, "/test1", func(logger simplelog.LogI, w http.ResponseWriter, q *http.Request) {
 
 	})
+
+	DefaultHub.SetLogger(l)
+	DefaultHub.SetHandler(DefaultDispatcher.WsHandler())
+	// rate limited like any route, an upgrade takes one token and the socket lives on without the limiter
+	SafeHttpHandle(l, http.MethodGet, "/ws", DefaultHub.Handler(), RequireLogin(), WithSlowThreshold(NoSlowThreshold))
+	SafeHttpHandle(l, http.MethodPost, "/msg/{id}", DefaultDispatcher.HttpHandler(), RequireLogin())
+
+	return DefaultRouter
 }
@@ -0,0 +1,224 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:43
+@Description: token bucket rate limit keyed by uid, ip or route
+*/
+
+package process
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"go.uber.org/zap"
+)
+
+// RateKey picks the bucket of a request, an empty key is not limited.
+type RateKey func(route *Route, logger simplelog.LogI, r *http.Request) string
+
+// KeyByUid limits per player, requests without a uid (SetUid not called yet) pass.
+func KeyByUid(_ *Route, logger simplelog.LogI, _ *http.Request) string {
+	if uid := logger.GetUid(); uid != 0 {
+		return "uid:" + strconv.FormatUint(uid, 10)
+	}
+	return ""
+}
+
+// KeyByIP limits per client address.
+func KeyByIP(_ *Route, _ simplelog.LogI, r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// KeyByRoute limits the route as a whole.
+func KeyByRoute(route *Route, _ simplelog.LogI, _ *http.Request) string {
+	return "route:" + route.Key()
+}
+
+// SkipRateLimit exempts the route from every RateLimiter, e.g. static assets loaded many per page.
+func SkipRateLimit() RouteOption {
+	return func(r *Route) {
+		r.skipRateLimit = true
+	}
+}
+
+const (
+	rateSweepInterval = time.Minute
+	rateIdleTTL       = 5 * time.Minute
+	rateTopKeys       = 10
+)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter is a set of token buckets, one per key.
+type RateLimiter struct {
+	name   string
+	key    RateKey
+	logger simplelog.LogI
+
+	mu        sync.Mutex
+	rate      float64 // tokens per second, 0 disables the limiter
+	burst     float64
+	buckets   map[string]*bucket
+	denied    map[string]int64 // denials since lastSweep, logged in one line
+	lastSweep time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewRateLimiter(l simplelog.LogI, name string, rate float64, burst int, key RateKey) *RateLimiter {
+	rl := &RateLimiter{
+		name:      name,
+		key:       key,
+		logger:    l,
+		buckets:   make(map[string]*bucket),
+		denied:    make(map[string]int64),
+		lastSweep: time.Now(),
+	}
+	rl.SetRate(rate, burst)
+	return rl
+}
+
+// SetRate changes the limit live, existing buckets keep their tokens up to the new burst.
+func (rl *RateLimiter) SetRate(rate float64, burst int) {
+	if burst < 1 {
+		burst = 1
+	}
+	rl.mu.Lock()
+	rl.rate = rate
+	rl.burst = float64(burst)
+	rl.mu.Unlock()
+}
+
+// Allow takes a token of key, when none is left it returns how long until the next one.
+func (rl *RateLimiter) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if now.Sub(rl.lastSweep) >= rateSweepInterval {
+		rl.sweep(now)
+	}
+	if rl.rate <= 0 {
+		return true, 0
+	}
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{tokens: rl.burst, last: now}
+		rl.buckets[key] = b
+	}
+	b.tokens = math.Min(rl.burst, b.tokens+now.Sub(b.last).Seconds()*rl.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	rl.denied[key]++
+	wait := time.Duration((1 - b.tokens) / rl.rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep drops idle buckets and logs the denials of the last window, rl.mu must be held.
+func (rl *RateLimiter) sweep(now time.Time) {
+	for key, b := range rl.buckets {
+		if now.Sub(b.last) > rateIdleTTL {
+			delete(rl.buckets, key)
+		}
+	}
+
+	if len(rl.denied) > 0 {
+		var total int64
+		keys := make([]string, 0, len(rl.denied))
+		for key, n := range rl.denied {
+			total += n
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return rl.denied[keys[i]] > rl.denied[keys[j]] })
+
+		top := make(map[string]int64)
+		for i := 0; i < len(keys) && i < rateTopKeys; i++ {
+			top[keys[i]] = rl.denied[keys[i]]
+		}
+		rl.logger.WarnWF("rate limit denied", zap.String("limiter", rl.name), zap.Int64("total", total),
+			zap.Int("keys", len(keys)), zap.Any("top", top), zap.Duration("window", now.Sub(rl.lastSweep)))
+		rl.denied = make(map[string]int64)
+	}
+	rl.lastSweep = now
+}
+
+// Flush logs the denials since the last sweep and drops idle buckets now.
+func (rl *RateLimiter) Flush() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.sweep(time.Now())
+}
+
+// Start sweeps every rateSweepInterval, so denials are logged even when no request comes
+// to trigger a sweep in Allow. It is the OnStart of the limiter hook.
+func (rl *RateLimiter) Start() error {
+	rl.stop, rl.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(rl.done)
+		ticker := time.NewTicker(rateSweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-rl.stop:
+				return
+			case <-ticker.C:
+				rl.Flush()
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop ends the sweeper and logs the last denials, the limiter keeps working for late requests.
+func (rl *RateLimiter) Stop(ctx context.Context) error {
+	if rl.stop != nil {
+		close(rl.stop)
+		select {
+		case <-rl.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	rl.Flush()
+	return nil
+}
+
+// Middleware returns 429 with Retry-After once the bucket of the request is empty.
+func (rl *RateLimiter) Middleware(route *Route, next HttpHandler) HttpHandler {
+	if route.skipRateLimit {
+		return next
+	}
+	return func(logger simplelog.LogI, params Params, w http.ResponseWriter, r *http.Request) {
+		key := rl.key(route, logger, r)
+		if key != "" {
+			if ok, wait := rl.Allow(key); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				writeJsonError(logger, w, http.StatusTooManyRequests, "too many requests")
+				return
+			}
+		}
+		next(logger, params, w, r)
+	}
+}
@@ -0,0 +1,101 @@
+package process
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+)
+
+func TestRateLimitByIP(t *testing.T) {
+	rl := NewRateLimiter(&simplelog.ZapLog{}, "ip", 1, 3, KeyByIP)
+	rt := newTestRouter()
+	rt.Use(rl.Middleware)
+	rt.GET("/a", func(simplelog.LogI, Params, http.ResponseWriter, *http.Request) {})
+
+	get := func(addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/a", nil)
+		req.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		if w := get("10.0.0.1:1000"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: expect 200, got %d", i, w.Code)
+		}
+	}
+	w := get("10.0.0.1:2000")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("expect 429 with Retry-After 1, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := get("10.0.0.2:1000"); w.Code != http.StatusOK {
+		t.Errorf("expect other ip unaffected, got %d", w.Code)
+	}
+}
+
+func TestRateLimitByUidSkipsAnonymous(t *testing.T) {
+	rl := NewRateLimiter(&simplelog.ZapLog{}, "uid", 1, 1, KeyByUid)
+	logger := &simplelog.ZapLog{}
+	req := httptest.NewRequest(http.MethodGet, "/a", nil)
+
+	if key := KeyByUid(nil, logger, req); key != "" {
+		t.Errorf("expect no key without uid, got %s", key)
+	}
+	logger.SetUid(10001)
+	key := KeyByUid(nil, logger, req)
+	if ok, _ := rl.Allow(key); !ok {
+		t.Error("expect first request allowed")
+	}
+	if ok, wait := rl.Allow(key); ok || wait <= 0 {
+		t.Errorf("expect second request denied with wait, got %v %v", ok, wait)
+	}
+}
+
+func TestRateLimitSetRateAndSweep(t *testing.T) {
+	rl := NewRateLimiter(&simplelog.ZapLog{}, "test", 1, 1, KeyByIP)
+	rl.Allow("a")
+	if ok, _ := rl.Allow("a"); ok {
+		t.Fatal("expect denied")
+	}
+
+	rl.SetRate(0, 1)
+	if ok, _ := rl.Allow("a"); !ok {
+		t.Error("expect rate 0 to disable the limiter")
+	}
+
+	rl.mu.Lock()
+	rl.buckets["a"].last = time.Now().Add(-2 * rateIdleTTL)
+	rl.lastSweep = time.Now().Add(-2 * rateSweepInterval)
+	rl.mu.Unlock()
+
+	rl.Allow("b")
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if _, ok := rl.buckets["a"]; ok || len(rl.denied) != 0 {
+		t.Errorf("expect idle bucket evicted and denials flushed, got %v %v", rl.buckets, rl.denied)
+	}
+}
+
+func TestRateLimitStopFlushes(t *testing.T) {
+	rl := NewRateLimiter(&simplelog.ZapLog{}, "test", 1, 1, KeyByIP)
+	if err := rl.Start(); err != nil {
+		t.Fatal(err)
+	}
+	rl.Allow("a")
+	rl.Allow("a")
+
+	// no more traffic, the denial is still logged on stop
+	if err := rl.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if len(rl.denied) != 0 {
+		t.Errorf("expect denials flushed on stop, got %v", rl.denied)
+	}
+}
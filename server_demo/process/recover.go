@@ -0,0 +1,63 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:35
+@Description: panic recovery for route handlers
+*/
+
+package process
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"go.uber.org/zap"
+)
+
+// RecoverMiddleware turns a handler panic into a json 500 and logs the panic with the request logger.
+func RecoverMiddleware(route *Route, next HttpHandler) HttpHandler {
+	return func(logger simplelog.LogI, params Params, w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				// the handler wants the connection dropped, let net/http do it
+				panic(err)
+			}
+
+			route.panics.Add(1)
+			httpPanics.With(methodLabel(r.Method), route.Pattern).Inc()
+			logger.ErrorWF("http panic", zap.String("method", r.Method), zap.String("pattern", route.Pattern),
+				zap.String("path", r.URL.Path), zap.String("panic", fmt.Sprint(err)),
+				zap.ByteString("stack", debug.Stack()))
+
+			if _, started := Status(w); started {
+				// headers are gone, the client gets a truncated body
+				return
+			}
+			writeJsonError(logger, w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		}()
+
+		next(logger, params, w, r)
+	}
+}
+
+// Panics returns how many times the route handler panicked.
+func (r *Route) Panics() int64 {
+	return r.panics.Load()
+}
+
+// PanicCounts returns the panic count of every route, keyed by "METHOD pattern".
+func (rt *Router) PanicCounts() map[string]int64 {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+
+	counts := make(map[string]int64, len(rt.routes))
+	for _, route := range rt.routes {
+		counts[route.Key()] = route.Panics()
+	}
+	return counts
+}
@@ -0,0 +1,49 @@
+package process
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+)
+
+func TestRecoverWritesJson500(t *testing.T) {
+	rt := newTestRouter()
+	route := rt.GET("/boom", func(simplelog.LogI, Params, http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})
+
+	for i := 0; i < 2; i++ {
+		w := doRequest(rt, http.MethodGet, "/boom")
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("expect 500, got %d", w.Code)
+		}
+		var body Envelope
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("expect json body, got %q: %v", w.Body.String(), err)
+		}
+		if body.Code != http.StatusInternalServerError || body.LogId == 0 {
+			t.Errorf("unexpected body %+v", body)
+		}
+	}
+
+	if route.Panics() != 2 {
+		t.Errorf("expect 2 panics, got %d", route.Panics())
+	}
+	if n := rt.PanicCounts()["GET /boom"]; n != 2 {
+		t.Errorf("expect 2 panics by key, got %d", n)
+	}
+}
+
+func TestRecoverAfterHeaderSent(t *testing.T) {
+	rt := newTestRouter()
+	rt.GET("/late", func(_ simplelog.LogI, _ Params, w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("late")
+	})
+
+	if w := doRequest(rt, http.MethodGet, "/late"); w.Code != http.StatusAccepted || w.Body.Len() != 0 {
+		t.Errorf("expect untouched 202, got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,92 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:35
+@Description: response writer wrapper
+*/
+
+package process
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// responseWriter records the status and size written by the handler.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	size        int64
+	wroteHeader bool
+	onHijack    func() // called once the connection is taken over, e.g. by a websocket upgrade
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Hijack records the upgrade as a 101, the handler owns the connection from here on.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.wroteHeader = true
+	w.status = http.StatusSwitchingProtocols
+	if w.onHijack != nil {
+		w.onHijack()
+	}
+	return conn, brw, nil
+}
+
+// Unwrap lets http.ResponseController reach Flush on the underlying writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// onHijack sets fn to run when the handler hijacks w, false when w is not from the router.
+func onHijack(w http.ResponseWriter, fn func()) bool {
+	for {
+		switch rw := w.(type) {
+		case *responseWriter:
+			rw.onHijack = fn
+			return true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return false
+		}
+	}
+}
+
+// Status returns the status written so far, and whether anything was sent to the client.
+func Status(w http.ResponseWriter) (status int, started bool) {
+	for {
+		switch rw := w.(type) {
+		case *responseWriter:
+			return rw.status, rw.wroteHeader
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return 0, false
+		}
+	}
+}
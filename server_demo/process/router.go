@@ -0,0 +1,380 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:33
+@Description: method and path template router
+*/
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"github.com/Xbzzy/client_demo/server_demo/common/util"
+	"go.uber.org/zap"
+)
+
+// Params holds the values extracted from the {name} segments of a route pattern.
+type Params map[string]string
+
+func (p Params) Get(name string) string {
+	if p == nil {
+		return ""
+	}
+	return p[name]
+}
+
+// HttpHandler is the handler signature used by Router, the logger is already cloned per request.
+type HttpHandler func(logger simplelog.LogI, params Params, w http.ResponseWriter, r *http.Request)
+
+type segmentKind int
+
+const (
+	segStatic segmentKind = iota
+	segParam
+	segWildcard // {name...}, matches the rest of the path
+)
+
+type segment struct {
+	kind  segmentKind
+	value string
+}
+
+// Route is a registered method + pattern pair.
+type Route struct {
+	Method  string // "" matches any method
+	Pattern string
+
+	segments     []segment
+	logger       simplelog.LogI // nil falls back to the router logger
+	handler      HttpHandler
+	middlewares  []Middleware
+	chain        HttpHandler // router and route middlewares around handler
+	optionsChain HttpHandler // the same middlewares around the automatic OPTIONS answer
+	router       *Router
+
+	bodyLimit     int64 // 0 uses the router default
+	timeout       time.Duration
+	slowThreshold time.Duration // 0 uses the router default
+	skipRateLimit bool
+	requireLogin  bool
+	roles         []string // any of them is required
+
+	panics atomic.Int64
+}
+
+// Key returns "METHOD pattern", ANY for routes that match every method.
+func (r *Route) Key() string {
+	if r.Method == "" {
+		return "ANY " + r.Pattern
+	}
+	return r.Method + " " + r.Pattern
+}
+
+type RouteOption func(*Route)
+
+// Router dispatches requests by method and path template, e.g. "POST /room/{id}/move".
+type Router struct {
+	logger simplelog.LogI
+
+	mu          sync.RWMutex
+	routes      []*Route
+	middlewares []Middleware
+
+	bodyLimit     atomic.Int64
+	slowThreshold atomic.Int64 // time.Duration
+}
+
+// DefaultRouter is used by SafeHttpRegister and served by InitHttp.
+var DefaultRouter = NewRouter(nil)
+
+func NewRouter(l simplelog.LogI) *Router {
+	rt := &Router{logger: l, middlewares: DefaultMiddlewares()}
+	rt.SetSlowThreshold(DefaultSlowThreshold)
+	return rt
+}
+
+// Use appends router level middlewares, they wrap every route including those already registered.
+func (rt *Router) Use(mws ...Middleware) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.middlewares = append(rt.middlewares, mws...)
+	for _, route := range rt.routes {
+		rt.build(route)
+	}
+}
+
+// build must be called with rt.mu held.
+func (rt *Router) build(route *Route) {
+	mws := make([]Middleware, 0, len(rt.middlewares)+len(route.middlewares))
+	mws = append(mws, rt.middlewares...)
+	mws = append(mws, route.middlewares...)
+	route.chain = Chain(route, route.handler, mws...)
+	route.optionsChain = Chain(route, rt.autoOptions, mws...)
+}
+
+// autoOptions answers OPTIONS for paths without an OPTIONS route, CorsMiddleware answers preflights before it.
+func (rt *Router) autoOptions(_ simplelog.LogI, _ Params, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Allow", strings.Join(rt.allowedMethods(splitPath(r.URL.Path)), ", "))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// allowedMethods returns the methods registered for the path, plus OPTIONS.
+func (rt *Router) allowedMethods(parts []string) []string {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+
+	allowed := []string{http.MethodOptions}
+	for _, route := range rt.routes {
+		if _, ok := route.match(parts); ok && route.Method != "" && !util.InStrings(allowed, route.Method) {
+			allowed = append(allowed, route.Method)
+		}
+	}
+	sort.Strings(allowed)
+	return allowed
+}
+
+// SetLogger sets the logger used for requests that do not match any route.
+func (rt *Router) SetLogger(l simplelog.LogI) {
+	rt.mu.Lock()
+	rt.logger = l
+	rt.mu.Unlock()
+}
+
+// Handle registers handler for method and pattern, an empty method matches any method.
+func (rt *Router) Handle(method, pattern string, handler HttpHandler, opts ...RouteOption) *Route {
+	return rt.handle(nil, method, pattern, handler, opts...)
+}
+
+func (rt *Router) GET(pattern string, handler HttpHandler, opts ...RouteOption) *Route {
+	return rt.Handle(http.MethodGet, pattern, handler, opts...)
+}
+
+func (rt *Router) POST(pattern string, handler HttpHandler, opts ...RouteOption) *Route {
+	return rt.Handle(http.MethodPost, pattern, handler, opts...)
+}
+
+func (rt *Router) PUT(pattern string, handler HttpHandler, opts ...RouteOption) *Route {
+	return rt.Handle(http.MethodPut, pattern, handler, opts...)
+}
+
+func (rt *Router) DELETE(pattern string, handler HttpHandler, opts ...RouteOption) *Route {
+	return rt.Handle(http.MethodDelete, pattern, handler, opts...)
+}
+
+func (rt *Router) handle(l simplelog.LogI, method, pattern string, handler HttpHandler, opts ...RouteOption) *Route {
+	if handler == nil {
+		panic(fmt.Sprintf("process: nil handler for %s %s", methodName(method), pattern))
+	}
+	segments, err := parsePattern(pattern)
+	if err != nil {
+		panic(fmt.Sprintf("process: invalid route %s %s: %v", methodName(method), pattern, err))
+	}
+
+	route := &Route{
+		Method:   strings.ToUpper(method),
+		Pattern:  pattern,
+		segments: segments,
+		logger:   l,
+		handler:  handler,
+		router:   rt,
+	}
+	for _, opt := range opts {
+		opt(route)
+	}
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	for _, exist := range rt.routes {
+		if exist.Method == route.Method && exist.Pattern == route.Pattern {
+			panic("process: duplicate route " + route.Method + " " + pattern)
+		}
+	}
+	rt.build(route)
+	rt.routes = append(rt.routes, route)
+	return route
+}
+
+// methodName names the method of a route in errors, "" matches any method.
+func methodName(method string) string {
+	if method == "" {
+		return "ANY"
+	}
+	return strings.ToUpper(method)
+}
+
+func parsePattern(pattern string) ([]segment, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, errors.New("must start with /")
+	}
+	parts := splitPath(pattern)
+	segments := make([]segment, 0, len(parts))
+	names := make(map[string]bool)
+	for i, part := range parts {
+		if !strings.HasPrefix(part, "{") || !strings.HasSuffix(part, "}") {
+			if strings.ContainsAny(part, "{}") {
+				return nil, fmt.Errorf("bad segment %q", part)
+			}
+			segments = append(segments, segment{kind: segStatic, value: part})
+			continue
+		}
+
+		name := part[1 : len(part)-1]
+		kind := segParam
+		if strings.HasSuffix(name, "...") {
+			if i != len(parts)-1 {
+				return nil, fmt.Errorf("{%s} must be the last segment", name)
+			}
+			name = strings.TrimSuffix(name, "...")
+			kind = segWildcard
+		}
+		if name == "" || names[name] {
+			return nil, fmt.Errorf("empty or duplicate param %q", part)
+		}
+		names[name] = true
+		segments = append(segments, segment{kind: kind, value: name})
+	}
+	return segments, nil
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// match reports whether path fits the route, and the extracted params.
+func (r *Route) match(parts []string) (Params, bool) {
+	var params Params
+	for i, seg := range r.segments {
+		if seg.kind == segWildcard {
+			if params == nil {
+				params = make(Params)
+			}
+			params[seg.value] = strings.Join(parts[i:], "/")
+			return params, true
+		}
+		if i >= len(parts) {
+			return nil, false
+		}
+		switch seg.kind {
+		case segStatic:
+			if seg.value != parts[i] {
+				return nil, false
+			}
+		case segParam:
+			if parts[i] == "" {
+				return nil, false
+			}
+			if params == nil {
+				params = make(Params)
+			}
+			params[seg.value] = parts[i]
+		}
+	}
+	return params, len(parts) == len(r.segments)
+}
+
+// moreSpecific reports whether r should win over b when both match: static beats param beats wildcard.
+func (r *Route) moreSpecific(b *Route) bool {
+	for i := 0; i < len(r.segments) && i < len(b.segments); i++ {
+		if r.segments[i].kind != b.segments[i].kind {
+			return r.segments[i].kind < b.segments[i].kind
+		}
+	}
+	return len(r.segments) > len(b.segments)
+}
+
+func (r *Route) allow(method string) bool {
+	return r.Method == "" || r.Method == method || (method == http.MethodHead && r.Method == http.MethodGet)
+}
+
+func (r *Route) serve(l simplelog.LogI, chain HttpHandler, params Params, w http.ResponseWriter, req *http.Request) {
+	if r.logger != nil {
+		l = r.logger
+	}
+	logger := l.Clone()
+	req = req.WithContext(simplelog.NewContext(req.Context(), logger))
+	chain(logger, params, newResponseWriter(w), req)
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	parts := splitPath(req.URL.Path)
+
+	preflightMethod := ""
+	if req.Method == http.MethodOptions {
+		preflightMethod = req.Header.Get("Access-Control-Request-Method")
+	}
+
+	rt.mu.RLock()
+	var (
+		best, other             *Route // other matches the path but not the method
+		bestParams, otherParams Params
+		allowed                 []string
+	)
+	for _, route := range rt.routes {
+		params, ok := route.match(parts)
+		if !ok {
+			continue
+		}
+		if !route.allow(req.Method) {
+			if !util.InStrings(allowed, route.Method) {
+				allowed = append(allowed, route.Method)
+			}
+			if other == nil || (route.Method == preflightMethod && other.Method != preflightMethod) {
+				other, otherParams = route, params
+			}
+			continue
+		}
+		if best == nil || route.moreSpecific(best) {
+			best, bestParams = route, params
+		}
+	}
+	logger := rt.logger
+	var chain HttpHandler
+	if best != nil {
+		chain = best.chain
+	} else if other != nil && req.Method == http.MethodOptions {
+		// run the middlewares of the route the preflight asks about, so CORS and logging apply
+		best, bestParams, chain = other, otherParams, other.optionsChain
+	}
+	rt.mu.RUnlock()
+
+	if best != nil {
+		best.serve(logger, chain, bestParams, w, req)
+		return
+	}
+
+	if len(allowed) > 0 {
+		sort.Strings(allowed)
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		rt.notServed(logger, http.StatusMethodNotAllowed, w, req)
+		return
+	}
+	rt.notServed(logger, http.StatusNotFound, w, req)
+}
+
+func (rt *Router) notServed(l simplelog.LogI, status int, w http.ResponseWriter, req *http.Request) {
+	id, _ := requestId(req)
+	w.Header().Set(RequestIdHeader, strconv.FormatInt(id, 10))
+	httpRequests.With(methodLabel(req.Method), unmatchedPattern, strconv.Itoa(status)).Inc()
+
+	var logger simplelog.LogI
+	if l != nil {
+		logger = l.Clone()
+		logger.SetLogId(id)
+		logger.InfoWF("http not served", zap.Int("status", status), zap.String("method", req.Method),
+			zap.String("path", req.URL.Path), zap.String("remoteAddr", req.RemoteAddr))
+	}
+	writeJsonError(logger, w, status, http.StatusText(status))
+}
@@ -0,0 +1,144 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+)
+
+func newTestRouter() *Router {
+	return NewRouter(&simplelog.ZapLog{})
+}
+
+func doRequest(h http.Handler, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func TestRouterParams(t *testing.T) {
+	rt := newTestRouter()
+	var got Params
+	rt.POST("/room/{id}/move", func(logger simplelog.LogI, params Params, w http.ResponseWriter, r *http.Request) {
+		if logger == nil {
+			t.Error("expect cloned logger")
+		}
+		got = params
+	})
+
+	w := doRequest(rt, http.MethodPost, "/room/42/move")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expect 200, got %d", w.Code)
+	}
+	if got.Get("id") != "42" {
+		t.Errorf("expect id 42, got %q", got.Get("id"))
+	}
+}
+
+func TestRouterNotFoundAndMethodNotAllowed(t *testing.T) {
+	rt := newTestRouter()
+	handler := func(simplelog.LogI, Params, http.ResponseWriter, *http.Request) {}
+	rt.POST("/room/{id}/move", handler)
+	rt.PUT("/room/{id}/move", handler)
+
+	if w := doRequest(rt, http.MethodGet, "/room/42/move"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expect 405, got %d", w.Code)
+	} else if allow := w.Header().Get("Allow"); allow != "POST, PUT" {
+		t.Errorf("expect Allow header POST, PUT, got %q", allow)
+	}
+	if w := doRequest(rt, http.MethodPost, "/room/42"); w.Code != http.StatusNotFound {
+		t.Errorf("expect 404, got %d", w.Code)
+	}
+}
+
+func TestRouterSpecificity(t *testing.T) {
+	rt := newTestRouter()
+	var hit string
+	rt.GET("/static/{path...}", func(_ simplelog.LogI, params Params, _ http.ResponseWriter, _ *http.Request) {
+		hit = "wildcard:" + params.Get("path")
+	})
+	rt.GET("/static/{name}", func(_ simplelog.LogI, params Params, _ http.ResponseWriter, _ *http.Request) {
+		hit = "param:" + params.Get("name")
+	})
+	rt.GET("/static/index.html", func(simplelog.LogI, Params, http.ResponseWriter, *http.Request) {
+		hit = "static"
+	})
+
+	cases := map[string]string{
+		"/static/index.html":  "static",
+		"/static/app.js":      "param:app.js",
+		"/static/js/main.js":  "wildcard:js/main.js",
+		"/static/css/a/b.css": "wildcard:css/a/b.css",
+	}
+	for path, expect := range cases {
+		hit = ""
+		doRequest(rt, http.MethodGet, path)
+		if hit != expect {
+			t.Errorf("%s: expect %s, got %s", path, expect, hit)
+		}
+	}
+}
+
+func TestRouterHeadFallsBackToGet(t *testing.T) {
+	rt := newTestRouter()
+	rt.GET("/ping", func(simplelog.LogI, Params, http.ResponseWriter, *http.Request) {})
+	if w := doRequest(rt, http.MethodHead, "/ping"); w.Code != http.StatusOK {
+		t.Errorf("expect 200, got %d", w.Code)
+	}
+}
+
+func TestParsePatternInvalid(t *testing.T) {
+	for _, pattern := range []string{"room", "/room/{id}/{id}", "/a/{rest...}/b", "/a/{}", "/a/b{c}"} {
+		if _, err := parsePattern(pattern); err == nil {
+			t.Errorf("expect %s to be rejected", pattern)
+		}
+	}
+
+	defer func() {
+		if msg := fmt.Sprint(recover()); !strings.Contains(msg, "POST /room/{id}/{id}") {
+			t.Errorf("expect the route in the panic, got %q", msg)
+		}
+	}()
+	newTestRouter().POST("/room/{id}/{id}", func(simplelog.LogI, Params, http.ResponseWriter, *http.Request) {})
+}
+
+func TestSafeHttpRegisterPrefix(t *testing.T) {
+	old := DefaultRouter
+	DefaultRouter = newTestRouter()
+	defer func() { DefaultRouter = old }()
+
+	var hits int
+	SafeHttpRegister(&simplelog.ZapLog{}, "/legacy/", func(simplelog.LogI, http.ResponseWriter, *http.Request) { hits++ })
+	for _, path := range []string{"/legacy/", "/legacy/a", "/legacy/a/b"} {
+		if w := doRequest(DefaultRouter, http.MethodGet, path); w.Code != http.StatusOK {
+			t.Errorf("%s: expect 200, got %d", path, w.Code)
+		}
+	}
+	if hits != 3 {
+		t.Errorf("expect 3 hits, got %d", hits)
+	}
+	if w := doRequest(DefaultRouter, http.MethodGet, "/legacyx"); w.Code != http.StatusNotFound {
+		t.Errorf("expect 404 outside the prefix, got %d", w.Code)
+	}
+}
+
+func TestRouterPutsLoggerOnContext(t *testing.T) {
+	rt := newTestRouter()
+	rt.GET("/a", func(logger simplelog.LogI, _ Params, _ http.ResponseWriter, r *http.Request) {
+		logger.SetUid(10001)
+		// deep code only sees the context
+		if l := simplelog.FromContext(r.Context()); l != logger || l.GetUid() != 10001 || l.GetLogId() == 0 {
+			t.Errorf("expect request logger on context, got %v", l)
+		}
+	})
+	doRequest(rt, http.MethodGet, "/a")
+
+	if l := simplelog.FromContext(context.Background()); l == nil {
+		t.Error("expect a fallback logger")
+	}
+}
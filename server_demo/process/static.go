@@ -0,0 +1,173 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:46
+@Description: serve the react_demo production builds with spa fallback
+*/
+
+package process
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"go.uber.org/zap"
+)
+
+const (
+	staticIndex       = "index.html"
+	staticGzipMinSize = 1 << 10
+
+	cacheImmutable = "public, max-age=31536000, immutable"
+	cacheShort     = "public, max-age=3600"
+	cacheNone      = "no-cache"
+)
+
+// hashedName matches the content hash react-scripts puts in asset names, e.g. main.1a2b3c4d.js.
+var hashedName = regexp.MustCompile(`\.[0-9a-f]{8,}\.`)
+
+type staticAsset struct {
+	name        string
+	data        []byte
+	gz          []byte // nil when gzip does not help
+	etag        string
+	contentType string
+	cache       string
+	modTime     time.Time
+}
+
+// StaticSite holds a whole build in memory, files are read and compressed once when it is created.
+type StaticSite struct {
+	prefix string
+	assets map[string]*staticAsset
+	index  *staticAsset
+}
+
+// NewStaticSite loads fsys (an embed.FS sub tree or os.DirFS of a build dir) to be served under prefix.
+func NewStaticSite(prefix string, fsys fs.FS) (*StaticSite, error) {
+	site := &StaticSite{prefix: "/" + strings.Trim(prefix, "/"), assets: make(map[string]*staticAsset)}
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		site.assets[name] = newStaticAsset(name, data, info.ModTime())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	site.index = site.assets[staticIndex]
+	if site.index == nil {
+		return nil, fs.ErrNotExist
+	}
+	return site, nil
+}
+
+func newStaticAsset(name string, data []byte, modTime time.Time) *staticAsset {
+	sum := sha1.Sum(data)
+	asset := &staticAsset{
+		name:        name,
+		data:        data,
+		etag:        `"` + hex.EncodeToString(sum[:8]) + `"`,
+		contentType: mime.TypeByExtension(path.Ext(name)),
+		modTime:     modTime,
+	}
+	if asset.contentType == "" {
+		asset.contentType = http.DetectContentType(data)
+	}
+
+	switch {
+	case path.Base(name) == staticIndex:
+		asset.cache = cacheNone
+	case hashedName.MatchString(path.Base(name)):
+		asset.cache = cacheImmutable
+	default:
+		asset.cache = cacheShort
+	}
+
+	if len(data) >= staticGzipMinSize && compressible(asset.contentType) {
+		var buf bytes.Buffer
+		zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		_, _ = zw.Write(data)
+		_ = zw.Close()
+		if buf.Len() < len(data) {
+			asset.gz = buf.Bytes()
+		}
+	}
+	return asset
+}
+
+func compressible(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/") || strings.Contains(contentType, "javascript") ||
+		strings.Contains(contentType, "json") || strings.Contains(contentType, "svg") ||
+		strings.Contains(contentType, "xml")
+}
+
+// lookup returns the asset of the path, or index.html for client side routes (no file extension).
+func (s *StaticSite) lookup(name string) *staticAsset {
+	name = strings.Trim(path.Clean("/"+name), "/")
+	if name == "" {
+		return s.index
+	}
+	if asset, ok := s.assets[name]; ok {
+		return asset
+	}
+	if path.Ext(name) == "" {
+		return s.index
+	}
+	return nil
+}
+
+// Handler serves the site, it expects the rest of the path in the "path" param.
+func (s *StaticSite) Handler() HttpHandler {
+	return func(logger simplelog.LogI, params Params, w http.ResponseWriter, r *http.Request) {
+		asset := s.lookup(params.Get("path"))
+		if asset == nil {
+			logger.DebugWF("static not found", zap.String("prefix", s.prefix), zap.String("path", r.URL.Path))
+			writeJsonError(logger, w, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+			return
+		}
+
+		h := w.Header()
+		h.Set("Content-Type", asset.contentType)
+		h.Set("Cache-Control", asset.cache)
+		h.Set("X-Content-Type-Options", "nosniff")
+
+		data, etag := asset.data, asset.etag
+		if asset.gz != nil {
+			h.Add("Vary", "Accept-Encoding")
+			// a range of the gzip stream is useless to the client, ranges get identity bytes
+			if acceptEncoding(r, "gzip") && r.Header.Get("Range") == "" {
+				h.Set("Content-Encoding", "gzip")
+				data, etag = asset.gz, strings.TrimSuffix(asset.etag, `"`)+`-gz"`
+			}
+		}
+		h.Set("ETag", etag)
+		http.ServeContent(w, r, asset.name, asset.modTime, bytes.NewReader(data))
+	}
+}
+
+// Static registers the site under its prefix on GET, e.g. /game and /game/{path...}.
+func (rt *Router) Static(site *StaticSite, opts ...RouteOption) {
+	opts = append([]RouteOption{SkipRateLimit()}, opts...)
+	rt.GET(site.prefix+"/{path...}", site.Handler(), opts...)
+}
@@ -0,0 +1,96 @@
+package process
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func newStaticRouter(t *testing.T) *Router {
+	js := strings.Repeat("console.log('tic tac toe');\n", 100)
+	site, err := NewStaticSite("/game", fstest.MapFS{
+		"index.html":                   {Data: []byte("<html>game</html>")},
+		"favicon.ico":                  {Data: []byte{0, 0, 1, 0}},
+		"static/js/main.1a2b3c4d.js":   {Data: []byte(js)},
+		"static/css/main.9f8e7d6c.css": {Data: []byte("body{}")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := newTestRouter()
+	rt.Static(site)
+	return rt
+}
+
+func TestStaticCacheAndFallback(t *testing.T) {
+	rt := newStaticRouter(t)
+
+	cases := []struct {
+		path, cache, body string
+		status            int
+	}{
+		{"/game", cacheNone, "<html>game</html>", http.StatusOK},
+		{"/game/", cacheNone, "<html>game</html>", http.StatusOK},
+		{"/game/room/12", cacheNone, "<html>game</html>", http.StatusOK},
+		{"/game/static/css/main.9f8e7d6c.css", cacheImmutable, "body{}", http.StatusOK},
+		{"/game/favicon.ico", cacheShort, "", http.StatusOK},
+		{"/game/static/js/missing.js", "", "", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		w := doRequest(rt, http.MethodGet, c.path)
+		if w.Code != c.status || w.Header().Get("Cache-Control") != c.cache {
+			t.Errorf("%s: expect %d %q, got %d %q", c.path, c.status, c.cache, w.Code, w.Header().Get("Cache-Control"))
+		}
+		if c.body != "" && w.Body.String() != c.body {
+			t.Errorf("%s: unexpected body %q", c.path, w.Body.String())
+		}
+	}
+}
+
+func TestStaticGzip(t *testing.T) {
+	rt := newStaticRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/game/static/js/main.1a2b3c4d.js", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, req)
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("expect gzip, got %v", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(zr)
+	if !strings.HasPrefix(string(data), "console.log") {
+		t.Errorf("unexpected content %q", data[:20])
+	}
+
+	// revalidation with the etag of the gzip variant
+	req = httptest.NewRequest(http.MethodGet, "/game/static/js/main.1a2b3c4d.js", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expect 304, got %d", w.Code)
+	}
+
+	w = doRequest(rt, http.MethodGet, "/game/static/js/main.1a2b3c4d.js")
+	if w.Header().Get("Content-Encoding") != "" || w.Body.Len() != 2800 {
+		t.Errorf("expect identity body without Accept-Encoding, got %q %d", w.Header().Get("Content-Encoding"), w.Body.Len())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/game/static/js/main.1a2b3c4d.js", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Range", "bytes=0-10")
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, req)
+	if w.Code != http.StatusPartialContent || w.Header().Get("Content-Encoding") != "" || w.Body.String() != "console.log" {
+		t.Errorf("expect an identity range, got %d %q %q", w.Code, w.Header().Get("Content-Encoding"), w.Body.String())
+	}
+}
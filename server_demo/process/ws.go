@@ -0,0 +1,374 @@
+/*
+@Author: agent
+@Date: 2026/10/16 21:05
+@Description: websocket endpoint, the server pushes to every connection of a uid
+*/
+
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/buffer"
+	"github.com/Xbzzy/client_demo/server_demo/common/idgen"
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"github.com/Xbzzy/client_demo/server_demo/common/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultPingInterval = 25 * time.Second
+	DefaultPongWait     = 10 * time.Second
+	wsSendQueue         = 64
+)
+
+var ErrWsSlow = errors.New("websocket send queue full")
+
+// WsMessage is the json text frame of every push.
+type WsMessage struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// WsSession is one websocket connection of a logged in player.
+type WsSession struct {
+	Id     int64
+	Uid    uint64
+	Logger simplelog.LogI // cloned from the hub logger, uid set and the session id as log id
+
+	ctx         context.Context
+	conn        *websocket.Conn
+	send        chan buffer.IoBuffer
+	done        chan struct{} // closed once the session is dead, Send refuses from then on
+	exited      chan struct{} // closed by writeLoop after the close frame went out
+	closeOnce   sync.Once
+	closeCode   int
+	closeReason string
+	flush       bool
+}
+
+// Context carries the claims of the upgrade request, so UidFrom works in message handlers.
+func (s *WsSession) Context() context.Context {
+	return s.ctx
+}
+
+// Send queues a json message, a client too slow to keep up is disconnected instead of blocking the caller.
+func (s *WsSession) Send(msgType string, v interface{}) error {
+	buf := buffer.GetIoBuffer(256)
+	if err := json.NewEncoder(buf).Encode(WsMessage{Type: msgType, Data: v}); err != nil {
+		_ = buffer.PutIoBuffer(buf)
+		return err
+	}
+
+	select {
+	case <-s.done:
+		_ = buffer.PutIoBuffer(buf)
+		return websocket.ErrClosed
+	default:
+	}
+	select {
+	case s.send <- buf:
+		return nil
+	default:
+		_ = buffer.PutIoBuffer(buf)
+		s.Logger.InfoWF("ws send queue full", zap.String("type", msgType))
+		// the queue of a slow client is dropped, waiting for it is what got us here
+		s.shutdown(websocket.ClosePolicyViolation, "too slow", false)
+		return ErrWsSlow
+	}
+}
+
+// Close ends the session with code, writeLoop sends what is still queued, then the close frame,
+// and the read loop returns. It never waits on the connection.
+func (s *WsSession) Close(code int, reason string) {
+	s.shutdown(code, reason, true)
+}
+
+func (s *WsSession) shutdown(code int, reason string, flush bool) {
+	s.closeOnce.Do(func() {
+		s.closeCode, s.closeReason, s.flush = code, reason, flush
+		close(s.done)
+	})
+}
+
+// Wait blocks until the close frame went out or ctx is done.
+func (s *WsSession) Wait(ctx context.Context) error {
+	select {
+	case <-s.exited:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// writeLoop sends the queue and the heartbeat pings, it owns every write after the upgrade.
+func (s *WsSession) writeLoop(pingInterval time.Duration) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	defer close(s.exited)
+	defer func() {
+		for {
+			select {
+			case buf := <-s.send:
+				_ = buffer.PutIoBuffer(buf)
+			default:
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-s.done:
+			if s.flush {
+				s.flushQueue()
+			}
+			_ = s.conn.Close(s.closeCode, s.closeReason)
+			return
+		default:
+		}
+
+		select {
+		case <-s.done:
+		case buf := <-s.send:
+			if !s.write(buf) {
+				s.shutdown(websocket.CloseGoingAway, "", false)
+			}
+		case <-ticker.C:
+			if err := s.conn.Ping(nil); err != nil {
+				s.shutdown(websocket.CloseGoingAway, "", false)
+			}
+		}
+	}
+}
+
+// flushQueue writes the messages queued before the session was closed.
+func (s *WsSession) flushQueue() {
+	for {
+		select {
+		case buf := <-s.send:
+			if !s.write(buf) {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (s *WsSession) write(buf buffer.IoBuffer) bool {
+	err := s.conn.WriteMessage(websocket.OpText, buf.Bytes())
+	_ = buffer.PutIoBuffer(buf)
+	if err != nil {
+		s.Logger.DebugWF("ws write fail", zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// WsHandler gets every message of a session, msg is given back to the pool after it returns.
+type WsHandler func(s *WsSession, op websocket.Opcode, msg buffer.IoBuffer)
+
+// Hub keeps the sessions by uid, a player may be connected from several devices.
+type Hub struct {
+	PingInterval   time.Duration
+	PongWait       time.Duration
+	MaxMessageSize int
+
+	mu       sync.RWMutex
+	logger   simplelog.LogI
+	handler  WsHandler
+	sessions map[uint64]map[int64]*WsSession
+	closed   bool
+}
+
+// DefaultHub serves /ws of DefaultRouter, see InitHttp.
+var DefaultHub = NewHub(nil)
+
+func NewHub(l simplelog.LogI) *Hub {
+	return &Hub{
+		PingInterval:   DefaultPingInterval,
+		PongWait:       DefaultPongWait,
+		MaxMessageSize: websocket.DefaultMaxMessageSize,
+		logger:         l,
+		sessions:       make(map[uint64]map[int64]*WsSession),
+	}
+}
+
+func (h *Hub) SetLogger(l simplelog.LogI) {
+	h.mu.Lock()
+	h.logger = l
+	h.mu.Unlock()
+}
+
+// SetHandler sets what to do with client messages, without one they are logged and dropped.
+func (h *Hub) SetHandler(fn WsHandler) {
+	h.mu.Lock()
+	h.handler = fn
+	h.mu.Unlock()
+}
+
+func (h *Hub) add(s *WsSession) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return false
+	}
+	if h.sessions[s.Uid] == nil {
+		h.sessions[s.Uid] = make(map[int64]*WsSession)
+	}
+	h.sessions[s.Uid][s.Id] = s
+	return true
+}
+
+func (h *Hub) remove(s *WsSession) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.sessions[s.Uid], s.Id)
+	if len(h.sessions[s.Uid]) == 0 {
+		delete(h.sessions, s.Uid)
+	}
+}
+
+// Push sends the message to every session of uid and returns how many got it queued.
+func (h *Hub) Push(uid uint64, msgType string, v interface{}) int {
+	h.mu.RLock()
+	list := make([]*WsSession, 0, len(h.sessions[uid]))
+	for _, s := range h.sessions[uid] {
+		list = append(list, s)
+	}
+	h.mu.RUnlock()
+
+	n := 0
+	for _, s := range list {
+		if s.Send(msgType, v) == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Count returns the number of open sessions.
+func (h *Hub) Count() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	n := 0
+	for _, m := range h.sessions {
+		n += len(m)
+	}
+	return n
+}
+
+// Close disconnects everyone with going away and refuses new sessions, it is the OnStop of the ws hook.
+// It returns once every session sent its queue and the close frame, or when ctx is done.
+func (h *Hub) Close(ctx context.Context) error {
+	h.mu.Lock()
+	h.closed = true
+	var list []*WsSession
+	for _, m := range h.sessions {
+		for _, s := range m {
+			list = append(list, s)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, s := range list {
+		s.Close(websocket.CloseGoingAway, "server shutdown")
+	}
+	// the pushes queued before shutdown, like the closing ones of the rooms, go out first
+	for _, s := range list {
+		if err := s.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler upgrades the request and reads the session until it ends, the route must RequireLogin.
+func (h *Hub) Handler() HttpHandler {
+	return func(logger simplelog.LogI, _ Params, w http.ResponseWriter, r *http.Request) {
+		uid := UidFrom(r.Context())
+		conn, err := websocket.Upgrade(w, r, websocket.Options{
+			MaxMessageSize: h.MaxMessageSize,
+			ReadTimeout:    h.PingInterval + h.PongWait,
+		})
+		if err != nil {
+			var he *websocket.HandshakeError
+			if errors.As(err, &he) {
+				writeJsonError(logger, w, he.Status, he.Msg)
+				return
+			}
+			logger.InfoWF("ws upgrade fail", zap.Error(err))
+			return
+		}
+
+		h.mu.RLock()
+		base := h.logger
+		h.mu.RUnlock()
+		if base == nil {
+			base = logger
+		}
+		s := &WsSession{
+			Id:     idgen.Next(),
+			Uid:    uid,
+			Logger: base.Clone(),
+			ctx:    WithSession(context.Background(), SessionFrom(r.Context())),
+			conn:   conn,
+			send:   make(chan buffer.IoBuffer, wsSendQueue),
+			done:   make(chan struct{}),
+			exited: make(chan struct{}),
+		}
+		s.Logger.SetUid(uid)
+		s.Logger.SetLogId(s.Id)
+
+		if !h.add(s) {
+			_ = conn.Close(websocket.CloseGoingAway, "server shutdown")
+			return
+		}
+		defer h.remove(s)
+		// a no-op after the normal close below, it keeps a panic from leaving writeLoop pinging
+		defer s.Close(websocket.CloseInternalError, "")
+		logger.InfoWF("ws connected", zap.Int64("sessionId", s.Id), zap.String("remoteAddr", r.RemoteAddr))
+		s.Logger.InfoWF("ws session start", zap.Int64("requestId", logger.GetLogId()))
+
+		go s.writeLoop(h.PingInterval)
+		start := time.Now()
+		for {
+			op, msg, err := conn.ReadMessage()
+			if err != nil {
+				s.Logger.InfoWF("ws session end", zap.Int("code", websocket.CloseCode(err)), zap.Error(err),
+					zap.Duration("cost", time.Since(start)))
+				break
+			}
+			h.mu.RLock()
+			handler := h.handler
+			h.mu.RUnlock()
+			if handler == nil {
+				s.Logger.DebugWF("ws message dropped", zap.Int("len", msg.Len()))
+			} else {
+				h.serve(handler, s, op, msg)
+			}
+			_ = buffer.PutIoBuffer(msg)
+		}
+		s.Close(websocket.CloseNormal, "")
+	}
+}
+
+// serve runs handler for one message, a panic is logged and the session keeps reading. The
+// connection is hijacked by then, RecoverMiddleware could not answer it anyway.
+func (h *Hub) serve(handler WsHandler, s *WsSession, op websocket.Opcode, msg buffer.IoBuffer) {
+	defer func() {
+		if err := recover(); err != nil {
+			s.Logger.ErrorWF("ws panic", zap.Int("op", int(op)), zap.String("panic", fmt.Sprint(err)),
+				zap.ByteString("stack", debug.Stack()))
+		}
+	}()
+	handler(s, op, msg)
+}
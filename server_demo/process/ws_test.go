@@ -0,0 +1,204 @@
+package process
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/auth"
+	"github.com/Xbzzy/client_demo/server_demo/common/buffer"
+	"github.com/Xbzzy/client_demo/server_demo/common/websocket"
+)
+
+func newWsServer(t *testing.T) (*Hub, *auth.Signer, *httptest.Server) {
+	signer, err := auth.NewSigner([]byte("0123456789abcdef"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := NewHub(nil)
+	rt := newTestRouter()
+	rt.Use(NewAuth(signer, nil).Middleware, CompressMiddleware(0))
+	rt.GET("/ws", hub.Handler(), RequireLogin())
+	srv := httptest.NewServer(rt)
+	t.Cleanup(srv.Close)
+	return hub, signer, srv
+}
+
+// wsDial does the handshake by hand and returns the raw connection.
+func wsDial(t *testing.T, srv *httptest.Server, token string) (net.Conn, *bufio.Reader, int) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	_, _ = io.WriteString(conn, "GET /ws?access_token="+token+" HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\n"+
+		"Upgrade: websocket\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+		"Accept-Encoding: gzip\r\n\r\n")
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, br, resp.StatusCode
+}
+
+func wsRecv(t *testing.T, br *bufio.Reader) (websocket.Opcode, []byte) {
+	var head [2]byte
+	if _, err := io.ReadFull(br, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	size := int(head[1] & 0x7f)
+	if size == 126 {
+		var ext [2]byte
+		if _, err := io.ReadFull(br, ext[:]); err != nil {
+			t.Fatal(err)
+		}
+		size = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(br, data); err != nil {
+		t.Fatal(err)
+	}
+	return websocket.Opcode(head[0] & 0x0f), data
+}
+
+func wsSend(conn net.Conn, op websocket.Opcode, payload string) {
+	key := [4]byte{9, 8, 7, 6}
+	frame := []byte{0x80 | byte(op), 0x80 | byte(len(payload))}
+	frame = append(frame, key[:]...)
+	for i := 0; i < len(payload); i++ {
+		frame = append(frame, payload[i]^key[i&3])
+	}
+	_, _ = conn.Write(frame)
+}
+
+func TestWsPush(t *testing.T) {
+	hub, signer, srv := newWsServer(t)
+	if _, _, status := wsDial(t, srv, ""); status != http.StatusUnauthorized {
+		t.Errorf("expect 401 without token, got %d", status)
+	}
+
+	token, _, _ := signer.Issue(42, "test")
+	conn, br, status := wsDial(t, srv, token)
+	if status != http.StatusSwitchingProtocols {
+		t.Fatalf("expect 101, got %d", status)
+	}
+	for i := 0; hub.Count() == 0 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if n := hub.Push(42, "room", map[string]int{"cell": 4}); n != 1 {
+		t.Fatalf("expect one session, got %d", n)
+	}
+	op, data := wsRecv(t, br)
+	var msg struct {
+		Type string         `json:"type"`
+		Data map[string]int `json:"data"`
+	}
+	if err := json.Unmarshal(data, &msg); op != websocket.OpText || err != nil || msg.Type != "room" || msg.Data["cell"] != 4 {
+		t.Errorf("unexpected push %v %s", op, data)
+	}
+
+	got := make(chan string, 1)
+	hub.SetHandler(func(s *WsSession, _ websocket.Opcode, msg buffer.IoBuffer) {
+		if s.Uid == 42 && s.Logger.GetUid() == 42 {
+			got <- msg.String()
+		}
+	})
+	wsSend(conn, websocket.OpText, "hi")
+	select {
+	case m := <-got:
+		if m != "hi" {
+			t.Errorf("unexpected message %q", m)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler not called")
+	}
+
+	_ = hub.Close(context.Background())
+	if op, data = wsRecv(t, br); op != websocket.OpClose || binary.BigEndian.Uint16(data) != websocket.CloseGoingAway {
+		t.Errorf("expect going away, got %v %v", op, data)
+	}
+}
+
+func TestWsHeartbeat(t *testing.T) {
+	hub, signer, srv := newWsServer(t)
+	hub.PingInterval, hub.PongWait = 30*time.Millisecond, 30*time.Millisecond
+
+	token, _, _ := signer.Issue(7, "test")
+	conn, br, _ := wsDial(t, srv, token)
+	if op, _ := wsRecv(t, br); op != websocket.OpPing {
+		t.Fatalf("expect ping, got %v", op)
+	}
+	wsSend(conn, websocket.OpPong, "")
+
+	// no more pongs, the server drops the connection after ping interval plus pong wait
+	for i := 0; hub.Count() != 0 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if hub.Count() != 0 {
+		t.Error("expect session removed")
+	}
+}
+
+func TestWsCloseFlushesQueue(t *testing.T) {
+	hub, signer, srv := newWsServer(t)
+	token, _, _ := signer.Issue(42, "test")
+	_, br, _ := wsDial(t, srv, token)
+	for i := 0; hub.Count() == 0 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// a full queue, writeLoop cannot have sent all of it before Close
+	for i := 0; i < wsSendQueue; i++ {
+		if n := hub.Push(42, "room_closed", i); n != 1 {
+			t.Fatalf("expect one session, got %d", n)
+		}
+	}
+	if err := hub.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < wsSendQueue; i++ {
+		op, data := wsRecv(t, br)
+		if op != websocket.OpText || !strings.Contains(string(data), `"room_closed"`) {
+			t.Fatalf("expect push %d before the close frame, got %v %s", i, op, data)
+		}
+	}
+	if op, data := wsRecv(t, br); op != websocket.OpClose || binary.BigEndian.Uint16(data) != websocket.CloseGoingAway {
+		t.Errorf("expect going away, got %v %v", op, data)
+	}
+}
+
+func TestWsHandlerPanic(t *testing.T) {
+	hub, signer, srv := newWsServer(t)
+	got := make(chan string, 1)
+	hub.SetHandler(func(s *WsSession, _ websocket.Opcode, msg buffer.IoBuffer) {
+		if msg.String() == "boom" {
+			panic("boom")
+		}
+		got <- msg.String()
+	})
+
+	token, _, _ := signer.Issue(42, "test")
+	conn, _, _ := wsDial(t, srv, token)
+	wsSend(conn, websocket.OpText, "boom")
+	wsSend(conn, websocket.OpText, "hi")
+	select {
+	case m := <-got:
+		if m != "hi" {
+			t.Errorf("unexpected message %q", m)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("session did not survive the panic")
+	}
+}
@@ -0,0 +1,165 @@
+/*
+@Author: agent
+@Date: 2026/10/16 20:51
+@Description: shop inventory behind react_demo/show_sell, editing is for shop staff only
+*/
+
+package shop
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/auth"
+	"github.com/Xbzzy/client_demo/server_demo/common/idgen"
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"github.com/Xbzzy/client_demo/server_demo/process"
+	"go.uber.org/zap"
+)
+
+// Product is one row of the show_sell product table.
+type Product struct {
+	Id       int64  `json:"id,string"`
+	Category string `json:"category"`
+	Name     string `json:"name"`
+	Price    string `json:"price"`
+	Stocked  bool   `json:"stocked"`
+}
+
+func (p *Product) Validate() error {
+	if p.Category == "" || p.Name == "" || p.Price == "" {
+		return errors.New("category, name and price are required")
+	}
+	return nil
+}
+
+// Shop keeps the inventory in memory.
+type Shop struct {
+	mu       sync.RWMutex
+	products map[int64]*Product
+}
+
+// NewShop returns a shop stocked like the show_sell demo.
+func NewShop() *Shop {
+	s := &Shop{products: make(map[int64]*Product)}
+	for _, p := range []Product{
+		{Category: "Fruits", Price: "$1", Stocked: true, Name: "Apple"},
+		{Category: "Fruits", Price: "$1", Stocked: true, Name: "DragonFruit"},
+		{Category: "Fruits", Price: "$2", Stocked: false, Name: "PassionFruit"},
+		{Category: "Vegetables", Price: "$2", Stocked: true, Name: "Spinach"},
+		{Category: "Vegetables", Price: "$4", Stocked: false, Name: "Pumpkin"},
+		{Category: "Vegetables", Price: "$1", Stocked: true, Name: "Peas"},
+	} {
+		p.Id = idgen.Next()
+		s.products[p.Id] = &p
+	}
+	return s
+}
+
+// List returns the products grouped by category, the table renders a header per category.
+func (s *Shop) List() []Product {
+	s.mu.RLock()
+	list := make([]Product, 0, len(s.products))
+	for _, p := range s.products {
+		list = append(list, *p)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Category != list[j].Category {
+			return list[i].Category < list[j].Category
+		}
+		return list[i].Id < list[j].Id
+	})
+	return list
+}
+
+// nameTaken reports whether another product uses name, the table keys rows by name. s.mu must be held.
+func (s *Shop) nameTaken(name string, id int64) bool {
+	for _, p := range s.products {
+		if p.Name == name && p.Id != id {
+			return true
+		}
+	}
+	return false
+}
+
+func parseId(params process.Params) (int64, error) {
+	id, err := strconv.ParseInt(params.Get("id"), 10, 64)
+	if err != nil {
+		return 0, process.BadRequest("bad product id")
+	}
+	return id, nil
+}
+
+func (s *Shop) list(_ context.Context, _ simplelog.LogI, _ process.Params, _ *struct{}) ([]Product, error) {
+	return s.List(), nil
+}
+
+func (s *Shop) create(_ context.Context, logger simplelog.LogI, _ process.Params, req *Product) (*Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.nameTaken(req.Name, 0) {
+		return nil, process.Conflict("product name exists")
+	}
+	p := *req
+	p.Id = idgen.Next()
+	s.products[p.Id] = &p
+	logger.InfoWF("shop product created", zap.Int64("productId", p.Id), zap.String("name", p.Name))
+	return &p, nil
+}
+
+func (s *Shop) update(_ context.Context, logger simplelog.LogI, params process.Params, req *Product) (*Product, error) {
+	id, err := parseId(params)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.products[id]
+	if !ok {
+		return nil, process.NotFound("product not found")
+	}
+	if s.nameTaken(req.Name, id) {
+		return nil, process.Conflict("product name exists")
+	}
+	p := *req
+	p.Id = id
+	s.products[id] = &p
+	logger.InfoWF("shop product updated", zap.Int64("productId", id), zap.Any("old", old), zap.Any("new", p))
+	return &p, nil
+}
+
+func (s *Shop) remove(_ context.Context, logger simplelog.LogI, params process.Params, _ *struct{}) (interface{}, error) {
+	id, err := parseId(params)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.products[id]
+	if !ok {
+		return nil, process.NotFound("product not found")
+	}
+	delete(s.products, id)
+	logger.InfoWF("shop product deleted", zap.Int64("productId", id), zap.Any("old", old))
+	return nil, nil
+}
+
+// Register mounts the shop api, anyone may list, editing needs the shop or operator role.
+func (s *Shop) Register(rt *process.Router) {
+	staff := process.RequireRoles(auth.RoleShop, auth.RoleOperator)
+
+	rt.GET("/shop/products", process.JsonHandler(s.list))
+	rt.POST("/shop/products", process.JsonHandler(s.create), staff)
+	rt.PUT("/shop/products/{id}", process.JsonHandler(s.update), staff)
+	rt.DELETE("/shop/products/{id}", process.JsonHandler(s.remove), staff)
+}
@@ -0,0 +1,82 @@
+package shop
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Xbzzy/client_demo/server_demo/common/auth"
+	"github.com/Xbzzy/client_demo/server_demo/common/simplelog"
+	"github.com/Xbzzy/client_demo/server_demo/process"
+)
+
+func newTestShop(t *testing.T) (*process.Router, string) {
+	signer, err := auth.NewSigner([]byte("0123456789abcdef"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := process.NewAuth(signer, nil)
+	rt := process.NewRouter(&simplelog.ZapLog{})
+	rt.Use(a.Middleware)
+	NewShop().Register(rt)
+
+	token, _, _ := signer.Issue(1, "test", auth.RoleShop)
+	return rt, token
+}
+
+func request(h http.Handler, method, path, token, body string) (*httptest.ResponseRecorder, process.Envelope) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	var env process.Envelope
+	_ = json.Unmarshal(w.Body.Bytes(), &env)
+	return w, env
+}
+
+func TestShopEdit(t *testing.T) {
+	rt, token := newTestShop(t)
+	body := `{"category":"Fruits","name":"Mango","price":"$3","stocked":true}`
+
+	if w, _ := request(rt, http.MethodPost, "/shop/products", "", body); w.Code != http.StatusUnauthorized {
+		t.Errorf("expect 401 without login, got %d", w.Code)
+	}
+	w, _ := request(rt, http.MethodPost, "/shop/products", token, body)
+	var created Product
+	if err := json.Unmarshal(w.Body.Bytes(), &process.Envelope{Data: &created}); err != nil || created.Id == 0 {
+		t.Fatalf("create: %d %s", w.Code, w.Body.String())
+	}
+	if w, _ = request(rt, http.MethodPost, "/shop/products", token, body); w.Code != http.StatusConflict {
+		t.Errorf("expect duplicate name conflict, got %d", w.Code)
+	}
+
+	path := "/shop/products/" + strconv.FormatInt(created.Id, 10)
+	if w, _ = request(rt, http.MethodPut, path, token, `{"category":"Fruits","name":"Mango","price":"$5"}`); w.Code != http.StatusOK {
+		t.Errorf("update: %d %s", w.Code, w.Body.String())
+	}
+
+	var list []Product
+	w, _ = request(rt, http.MethodGet, "/shop/products", "", "")
+	_ = json.Unmarshal(w.Body.Bytes(), &process.Envelope{Data: &list})
+	if len(list) != 7 || list[0].Category != "Fruits" || list[len(list)-1].Category != "Vegetables" {
+		t.Fatalf("unexpected list %s", w.Body.String())
+	}
+	for _, p := range list {
+		if p.Name == "Mango" && (p.Price != "$5" || p.Stocked) {
+			t.Errorf("expect updated mango, got %+v", p)
+		}
+	}
+
+	if w, _ = request(rt, http.MethodDelete, path, token, ""); w.Code != http.StatusOK {
+		t.Errorf("delete: %d", w.Code)
+	}
+	if w, _ = request(rt, http.MethodDelete, path, token, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expect 404 after delete, got %d", w.Code)
+	}
+}